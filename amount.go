@@ -0,0 +1,108 @@
+package autop2p
+
+import "errors"
+
+type InvestUnit struct {
+	Min  int
+	Step int
+}
+
+var investUnits = map[CompanyType]InvestUnit{
+	Honestfund: {Min: 10000, Step: 10000},
+	Peoplefund: {Min: 10000, Step: 10000},
+}
+
+func (c CompanyType) InvestUnit() InvestUnit {
+	return investUnits[c]
+}
+
+// RoundAmount rounds amount down to the company's step, or to 0 when that
+// falls below its minimum.
+func (c CompanyType) RoundAmount(amount int) int {
+	unit := c.InvestUnit()
+	if unit.Step > 0 {
+		amount -= amount % unit.Step
+	}
+	if amount < unit.Min {
+		return 0
+	}
+	return amount
+}
+
+// AmountRule applies to products in all of its bands. Empty Categories and
+// zero maximums leave that band open.
+type AmountRule struct {
+	Categories []Category
+	RateMin    float64 `yaml:"rateMin"`
+	RateMax    float64 `yaml:"rateMax"`
+	PeriodMin  int     `yaml:"periodMin"`
+	PeriodMax  int     `yaml:"periodMax"`
+	Amount     int
+}
+
+func (r *AmountRule) match(product *Product) bool {
+	if len(r.Categories) > 0 && !containsCategory(r.Categories, product.Category) {
+		return false
+	}
+	if product.Rate < r.RateMin || r.RateMax != 0 && product.Rate > r.RateMax {
+		return false
+	}
+	if product.Period < r.PeriodMin || r.PeriodMax != 0 && product.Period > r.PeriodMax {
+		return false
+	}
+	return true
+}
+
+// AmountFor returns the amount of the first matching rule in Amounts, falling
+// back to Amount. 0 means the setting doesn't invest in product at all.
+func (s *Setting) AmountFor(product *Product) int {
+	amount := s.Amount
+	for i := range s.Amounts {
+		if s.Amounts[i].match(product) {
+			amount = s.Amounts[i].Amount
+			break
+		}
+	}
+	return s.Company.RoundAmount(amount)
+}
+
+// FillAmount is AmountFor clamped to what product has left, when partial fill
+// allows investing that much. 0 means the setting doesn't invest in product.
+func (s *Setting) FillAmount(product *Product) int {
+	amount := s.AmountFor(product)
+	if amount <= product.RemainAmount {
+		return amount
+	}
+	if !s.PartialFill {
+		return 0
+	}
+	reduced := s.Company.RoundAmount(product.RemainAmount)
+	if reduced < s.PartialFillMin {
+		return 0
+	}
+	return reduced
+}
+
+// PartialAmount returns the reduced amount to retry with after err rejected
+// amount, and false when partial fill is off or nothing worth investing is left.
+func (s *Setting) PartialAmount(amount int, err error) (int, bool) {
+	if !s.PartialFill {
+		return 0, false
+	}
+
+	var investErr *InvestError
+	if !errors.As(err, &investErr) {
+		return 0, false
+	}
+	switch investErr.Code {
+	case InsufficientCapacity, InsufficientBalance, LimitExceeded:
+	default:
+		return 0, false
+	}
+
+	reduced := s.Company.RoundAmount(investErr.Available)
+	if reduced == 0 || reduced >= amount || reduced < s.PartialFillMin {
+		return 0, false
+	}
+	return reduced, true
+}
@@ -0,0 +1,96 @@
+package autop2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetting_AmountFor(t *testing.T) {
+	s := &Setting{
+		Company: Peoplefund,
+		Amount:  20000,
+		Amounts: []AmountRule{
+			{Categories: []Category{MortgageRealEstate}, RateMin: 10, PeriodMax: 6, Amount: 100000},
+			{Categories: []Category{MortgageRealEstate}, Amount: 50000},
+			{Categories: []Category{PersonalCredit}, Amount: 15000},
+		},
+	}
+
+	assert.Equal(t, 100000, s.AmountFor(&Product{Category: MortgageRealEstate, Rate: 11, Period: 6}))
+	assert.Equal(t, 50000, s.AmountFor(&Product{Category: MortgageRealEstate, Rate: 11, Period: 12}))
+	assert.Equal(t, 50000, s.AmountFor(&Product{Category: MortgageRealEstate, Rate: 9, Period: 3}))
+	assert.Equal(t, 10000, s.AmountFor(&Product{Category: PersonalCredit, Rate: 15, Period: 12}), "rounded down to the step")
+	assert.Equal(t, 20000, s.AmountFor(&Product{Category: PF, Rate: 15, Period: 12}))
+}
+
+func TestSetting_Match_AmountRules(t *testing.T) {
+	s := &Setting{
+		Company:    Honestfund,
+		PeriodMax:  12,
+		RateMax:    20,
+		Categories: []Category{MortgageRealEstate, PersonalCredit},
+		Amounts: []AmountRule{
+			{Categories: []Category{MortgageRealEstate}, Amount: 100000},
+		},
+	}
+
+	assert.True(t, s.Match(&Product{Category: MortgageRealEstate, Rate: 10, Period: 6, RemainAmount: 100000}))
+	assert.False(t, s.Match(&Product{Category: MortgageRealEstate, Rate: 10, Period: 6, RemainAmount: 50000}))
+
+	reasons := s.Explain(&Product{Category: PersonalCredit, Rate: 10, Period: 6, RemainAmount: 1000000})
+	assert.Equal(t, []Reason{{NoAmount, "no amount applies to the product"}}, reasons)
+}
+
+func TestCompanyType_RoundAmount(t *testing.T) {
+	assert.Equal(t, 30000, Honestfund.RoundAmount(35000))
+	assert.Equal(t, 0, Honestfund.RoundAmount(9999))
+	assert.Equal(t, 10000, Peoplefund.RoundAmount(10000))
+}
+
+func TestSetting_PartialAmount(t *testing.T) {
+	s := &Setting{Company: Honestfund, PartialFill: true, PartialFillMin: 20000}
+
+	amount, ok := s.PartialAmount(50000, &InvestError{Code: InsufficientBalance, Available: 35000})
+	assert.True(t, ok)
+	assert.Equal(t, 30000, amount)
+
+	amount, ok = s.PartialAmount(50000, &InvestError{Code: LimitExceeded, Available: 20000})
+	assert.True(t, ok)
+	assert.Equal(t, 20000, amount)
+
+	_, ok = s.PartialAmount(50000, &InvestError{Code: InsufficientCapacity, Available: 15000})
+	assert.False(t, ok, "below partialFillMin")
+
+	_, ok = s.PartialAmount(50000, &InvestError{Code: InsufficientCapacity, Available: 50000})
+	assert.False(t, ok, "not smaller than the requested amount")
+
+	_, ok = s.PartialAmount(50000, &InvestError{Code: Duplicated})
+	assert.False(t, ok)
+
+	_, ok = s.PartialAmount(50000, nil)
+	assert.False(t, ok)
+
+	s.PartialFill = false
+	_, ok = s.PartialAmount(50000, &InvestError{Code: InsufficientBalance, Available: 35000})
+	assert.False(t, ok)
+}
+
+func TestSetting_FillAmount(t *testing.T) {
+	s := &Setting{Company: Honestfund, Amount: 50000, PartialFill: true, PartialFillMin: 20000, PeriodMax: 12, RateMax: 20, Categories: []Category{PF}}
+	product := &Product{Category: PF, Rate: 10, Period: 6, RemainAmount: 35000}
+
+	assert.Equal(t, 30000, s.FillAmount(product))
+	assert.Empty(t, s.ExplainListing(product))
+
+	product.RemainAmount = 15000
+	assert.Equal(t, 0, s.FillAmount(product), "below partialFillMin")
+	assert.Equal(t, AmountExceedsRemaining, s.ExplainListing(product)[0].Code)
+
+	product.RemainAmount = 100000
+	assert.Equal(t, 50000, s.FillAmount(product))
+
+	s.PartialFill = false
+	product.RemainAmount = 35000
+	assert.Equal(t, 0, s.FillAmount(product))
+	assert.Equal(t, AmountExceedsRemaining, s.ExplainListing(product)[0].Code)
+}
@@ -0,0 +1,67 @@
+package autop2p
+
+import (
+	"context"
+	"sync"
+)
+
+// Catalog fetches each company's open products at most once per run and
+// shares them between the settings of that company.
+type Catalog struct {
+	list func(ctx context.Context, company CompanyType) ([]Product, error)
+
+	mu      sync.Mutex
+	entries map[CompanyType]*catalogEntry
+}
+
+type catalogEntry struct {
+	done     chan struct{}
+	products []Product
+	err      error
+}
+
+func NewCatalog(list func(ctx context.Context, company CompanyType) ([]Product, error)) *Catalog {
+	return &Catalog{list: list, entries: make(map[CompanyType]*catalogEntry)}
+}
+
+// Prefetch starts fetching the companies in parallel without waiting for them.
+func (c *Catalog) Prefetch(ctx context.Context, companies ...CompanyType) {
+	for _, company := range companies {
+		c.entry(ctx, company)
+	}
+}
+
+// Products returns the company's products, fetching them on first use. Every
+// call gets its own copy, so callers may load details into it or reorder it.
+// A failed fetch is not retried within the run.
+func (c *Catalog) Products(ctx context.Context, company CompanyType) ([]Product, error) {
+	e := c.entry(ctx, company)
+	select {
+	case <-e.done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	if e.err != nil {
+		return nil, e.err
+	}
+	return append([]Product(nil), e.products...), nil
+}
+
+// entry fetches with the context of the first caller, so the run context
+// should be the one to Prefetch or ask first.
+func (c *Catalog) entry(ctx context.Context, company CompanyType) *catalogEntry {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	e, ok := c.entries[company]
+	if !ok {
+		e = &catalogEntry{done: make(chan struct{})}
+		c.entries[company] = e
+		go func() {
+			defer close(e.done)
+			e.products, e.err = c.list(ctx, company)
+		}()
+	}
+	return e
+}
@@ -0,0 +1,62 @@
+package autop2p
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+)
+
+func TestCatalog_Products(t *testing.T) {
+	var mu sync.Mutex
+	calls := map[CompanyType]int{}
+	catalog := NewCatalog(func(ctx context.Context, company CompanyType) ([]Product, error) {
+		mu.Lock()
+		calls[company]++
+		mu.Unlock()
+		if company == Peoplefund {
+			return nil, errors.New("maintenance")
+		}
+		return []Product{{Id: "1", Company: company}, {Id: "2", Company: company}}, nil
+	})
+	catalog.Prefetch(context.Background(), Honestfund, Peoplefund)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			products, err := catalog.Products(context.Background(), Honestfund)
+			assert.Nil(t, err)
+			assert.Len(t, products, 2)
+			products[0].Title = "changed"
+		}()
+	}
+	wg.Wait()
+
+	products, err := catalog.Products(context.Background(), Honestfund)
+	assert.Nil(t, err)
+	assert.Equal(t, "", products[0].Title, "each caller gets its own copy")
+
+	_, err = catalog.Products(context.Background(), Peoplefund)
+	assert.EqualError(t, err, "maintenance")
+	_, err = catalog.Products(context.Background(), Peoplefund)
+	assert.EqualError(t, err, "maintenance")
+
+	assert.Equal(t, map[CompanyType]int{Honestfund: 1, Peoplefund: 1}, calls)
+}
+
+func TestCatalog_Products_Canceled(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	catalog := NewCatalog(func(ctx context.Context, company CompanyType) ([]Product, error) {
+		<-release
+		return nil, nil
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := catalog.Products(ctx, Honestfund)
+	assert.Equal(t, context.Canceled, err)
+}
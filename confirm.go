@@ -0,0 +1,69 @@
+package autop2p
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Joddev/autop2p/util"
+	"strings"
+)
+
+// MessageCode maps a keyword of a platform's invest response message to an
+// InvestError code.
+type MessageCode struct {
+	Keyword string
+	Code    string
+}
+
+// ParseInvestMessage classifies a refused investment by the first keyword in
+// codes found in message, falling back to Rejected.
+func ParseInvestMessage(message string, codes []MessageCode) *InvestError {
+	for _, c := range codes {
+		if strings.Contains(message, c.Keyword) {
+			return &InvestError{Code: c.Code, Message: message}
+		}
+	}
+	return &InvestError{Code: Rejected, Message: message}
+}
+
+// ConfirmInvestment settles an invest request against the holdings listed
+// after it. requestErr is the request's error and, when it is nil, outcome is
+// what the response said.
+func ConfirmInvestment(ctx context.Context, productId string, requestErr error, outcome error, listHoldings func(ctx context.Context) ([]Holding, error)) error {
+	// Only a refusal of the session is known not to have invested. Any other
+	// failure, like a timeout or an unreadable response, is settled by the
+	// holdings listing.
+	var expired *util.SessionExpiredError
+	if errors.As(requestErr, &expired) {
+		return requestErr
+	}
+
+	holdings, err := listHoldings(ctx)
+	if err != nil {
+		if requestErr == nil && outcome != nil {
+			return outcome
+		}
+		return &InvestError{Code: Unconfirmed, Message: fmt.Sprintf("can't list holdings: %v", err)}
+	}
+
+	held := false
+	for _, h := range holdings {
+		if h.ProductId == productId {
+			held = true
+			break
+		}
+	}
+
+	switch {
+	case requestErr != nil && held:
+		return nil
+	case requestErr != nil:
+		return &InvestError{Code: Unconfirmed, Message: fmt.Sprintf("request failed with %q and product not in holdings", requestErr)}
+	case held && outcome == nil, !held && outcome != nil:
+		return outcome
+	case held:
+		return &InvestError{Code: Unconfirmed, Message: fmt.Sprintf("response failed with %q but product is in holdings", outcome)}
+	default:
+		return &InvestError{Code: Unconfirmed, Message: "response succeeded but product is not in holdings"}
+	}
+}
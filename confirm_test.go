@@ -0,0 +1,62 @@
+package autop2p
+
+import (
+	"context"
+	"errors"
+	"github.com/Joddev/autop2p/util"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseInvestMessage(t *testing.T) {
+	codes := []MessageCode{{Keyword: "이미", Code: Duplicated}, {Keyword: "한도", Code: LimitExceeded}}
+
+	assert.Equal(t, &InvestError{Code: LimitExceeded, Message: "투자 한도 초과"}, ParseInvestMessage("투자 한도 초과", codes))
+	assert.Equal(t, &InvestError{Code: Rejected, Message: "점검 중"}, ParseInvestMessage("점검 중", codes))
+}
+
+func TestConfirmInvestment(t *testing.T) {
+	held := []Holding{{ProductId: "1"}}
+	refused := &InvestError{Code: Duplicated}
+	timeout := errors.New("timeout")
+
+	tests := []struct {
+		name       string
+		holdings   []Holding
+		listErr    error
+		requestErr error
+		outcome    error
+		code       string
+	}{
+		{name: "success in holdings", holdings: held},
+		{name: "refusal not in holdings", outcome: refused, code: Duplicated},
+		{name: "success not in holdings", code: Unconfirmed},
+		{name: "refusal in holdings", holdings: held, outcome: refused, code: Unconfirmed},
+		{name: "failed request in holdings", holdings: held, requestErr: timeout},
+		{name: "failed request not in holdings", requestErr: timeout, code: Unconfirmed},
+		{name: "refusal without holdings", listErr: timeout, outcome: refused, code: Duplicated},
+		{name: "success without holdings", listErr: timeout, code: Unconfirmed},
+		{name: "failed request without holdings", listErr: timeout, requestErr: timeout, code: Unconfirmed},
+	}
+	for _, tt := range tests {
+		err := ConfirmInvestment(context.Background(), "1", tt.requestErr, tt.outcome, func(ctx context.Context) ([]Holding, error) {
+			return tt.holdings, tt.listErr
+		})
+		if tt.code == "" {
+			assert.Nil(t, err, tt.name)
+			continue
+		}
+		var investErr *InvestError
+		assert.True(t, errors.As(err, &investErr), tt.name)
+		assert.Equal(t, tt.code, investErr.Code, tt.name)
+	}
+}
+
+func TestConfirmInvestment_SessionExpired(t *testing.T) {
+	expired := &util.SessionExpiredError{}
+	err := ConfirmInvestment(context.Background(), "1", expired, nil, func(ctx context.Context) ([]Holding, error) {
+		t.Fatal("holdings listed with an expired session")
+		return nil, nil
+	})
+	assert.Equal(t, expired, err)
+}
@@ -0,0 +1,29 @@
+package autop2p
+
+import "fmt"
+
+type AuthError struct {
+	Company CompanyType
+	Reason  string
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%s authentication failed: %s", e.Company, e.Reason)
+}
+
+type LayoutError struct {
+	Company CompanyType
+	Reason  string
+	Err     error
+}
+
+func (e *LayoutError) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s layout changed: %s: %v", e.Company, e.Reason, e.Err)
+	}
+	return fmt.Sprintf("%s layout changed: %s", e.Company, e.Reason)
+}
+
+func (e *LayoutError) Unwrap() error {
+	return e.Err
+}
@@ -1,7 +1,9 @@
 package honestfund
 
 import (
-	"errors"
+	"context"
+	"fmt"
+	"github.com/Joddev/autop2p"
 	"github.com/Joddev/autop2p/util"
 	"io/ioutil"
 	"net/http"
@@ -10,11 +12,12 @@ import (
 )
 
 type Api interface {
-	ListProducts(req *ListProductRequest) *ListProductResponse
-	Login(email string, password string) string
-	Invest(accessToken string, req *InvestRequest)
-	GetInvestConfirmHtml(accessToken string, productId string, amount int) []byte
-	ListInvestedProduct(accessToken string, req *ListInvestedProductsRequest) *ListInvestedProductsResponse
+	ListProducts(ctx context.Context, req *ListProductRequest) (*ListProductResponse, error)
+	GetProductDetail(ctx context.Context, productId string) (*ProductDetailResponse, error)
+	Login(ctx context.Context, email string, password string) (string, error)
+	Invest(ctx context.Context, accessToken string, req *InvestRequest) (*InvestResponse, error)
+	GetInvestConfirmHtml(ctx context.Context, accessToken string, productId string, amount int) ([]byte, error)
+	ListInvestedProduct(ctx context.Context, accessToken string, req *ListInvestedProductsRequest) (*ListInvestedProductsResponse, error)
 }
 
 type ApiImpl struct {
@@ -25,21 +28,41 @@ func NewApi(client *http.Client) Api {
 	return &ApiImpl{client}
 }
 
-func (a *ApiImpl) ListProducts(req *ListProductRequest) *ListProductResponse {
-	resp := util.HandleResponse(a.client.Post(
+func (a *ApiImpl) ListProducts(ctx context.Context, req *ListProductRequest) (*ListProductResponse, error) {
+	body, err := util.EncodeJsonRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
+		"POST",
 		"https://www.honestfund.kr/api/search/product/cl",
-		"application/json",
-		util.EncodeJsonRequest(req),
-	))
+		body,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq = util.Retryable(httpReq)
+	addJsonContentType(httpReq)
+
+	resp, err := util.HandleResponse(a.client.Do(httpReq))
+	if err != nil {
+		return nil, err
+	}
 
 	ret := &ListProductResponse{}
-	util.DecodeJsonResponse(resp, ret)
+	if err := util.DecodeJsonResponse(resp, ret); err != nil {
+		return nil, err
+	}
 
-	return ret
+	return ret, nil
 }
 
 type ListProductRequest struct {
 	Category     []string `json:"category"`
+	Index        int      `json:"index"`
 	PageSize     int      `json:"pageSize"`
 	Scroll       bool     `json:"scroll"`
 	State        []int    `json:"state"`
@@ -62,8 +85,50 @@ type ListProductResponse struct {
 	}
 }
 
-func (a *ApiImpl) Login(email string, password string) string {
-	res := util.HandleResponse(a.client.PostForm(
+func (a *ApiImpl) GetProductDetail(ctx context.Context, productId string) (*ProductDetailResponse, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		fmt.Sprintf("https://www.honestfund.kr/api/product/%s", productId),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := util.HandleResponse(a.client.Do(req))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ProductDetailResponse{}
+	if err := util.DecodeJsonResponse(resp, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+type ProductDetailResponse struct {
+	Code int
+	Data struct {
+		Product struct {
+			Uid                int
+			Ltv                float64
+			Grade              string
+			CollateralType     string
+			CollateralValue    int
+			BorrowerUid        int
+			GoalAmount         int
+			ProgressPercentage float64
+			RecruitEndDate     string
+		}
+	}
+}
+
+func (a *ApiImpl) Login(ctx context.Context, email string, password string) (string, error) {
+	httpReq, err := util.NewFormRequest(
+		ctx,
 		"https://www.honestfund.kr/login",
 		url.Values{
 			"email":             {email},
@@ -72,29 +137,58 @@ func (a *ApiImpl) Login(email string, password string) string {
 			"next":              {"/"},
 			"checkLoginKeeping": {"false"},
 		},
-	))
+	)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := util.HandleResponse(a.client.Do(httpReq))
+	if err != nil {
+		return "", err
+	}
 	defer res.Body.Close()
 
 	for _, cookie := range res.Cookies() {
 		if cookie.Name == "accessToken" && cookie.Value != "" {
-			return cookie.Value
+			return cookie.Value, nil
 		}
 	}
-	panic(errors.New("can't find accessToken from cookies"))
+	return "", &autop2p.AuthError{
+		Company: autop2p.Honestfund,
+		Reason:  "can't find accessToken from cookies",
+	}
 }
 
-func (a *ApiImpl) Invest(accessToken string, req *InvestRequest) {
-	httpReq, _ := http.NewRequest(
+func (a *ApiImpl) Invest(ctx context.Context, accessToken string, req *InvestRequest) (*InvestResponse, error) {
+	body, err := util.EncodeJsonRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		"https://www.honestfund.kr/invest/confirm",
-		util.EncodeJsonRequest(req),
+		body,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	addJsonContentType(httpReq)
 	addAccessTokenCookie(httpReq, accessToken)
 
-	res := util.HandleResponse(a.client.Do(httpReq))
-	defer res.Body.Close()
+	res, err := a.doWithSession(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	data := &InvestResponse{}
+	if err := util.DecodeJsonResponse(res, data); err != nil {
+		return nil, err
+	}
+
+	return data, nil
 }
 
 type InvestRequest struct {
@@ -102,12 +196,21 @@ type InvestRequest struct {
 	InvestAmount int `json:"investAmount"`
 }
 
-func (a *ApiImpl) GetInvestConfirmHtml(accessToken string, productId string, amount int) []byte {
-	req, _ := http.NewRequest(
+type InvestResponse struct {
+	Code    int
+	Message string
+}
+
+func (a *ApiImpl) GetInvestConfirmHtml(ctx context.Context, accessToken string, productId string, amount int) ([]byte, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		"https://www.honestfund.kr/invest/confirm",
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	q := req.URL.Query()
 	q.Add("productUid", productId)
@@ -116,34 +219,46 @@ func (a *ApiImpl) GetInvestConfirmHtml(accessToken string, productId string, amo
 
 	addAccessTokenCookie(req, accessToken)
 
-	res := util.HandleResponse(a.client.Do(req))
-	defer res.Body.Close()
-
-	data, err := ioutil.ReadAll(res.Body)
+	res, err := a.doWithSession(req)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	defer res.Body.Close()
 
-	return data
+	return ioutil.ReadAll(res.Body)
 }
 
-func (a *ApiImpl) ListInvestedProduct(accessToken string, req *ListInvestedProductsRequest) *ListInvestedProductsResponse {
-	httpReq, _ := http.NewRequest(
+func (a *ApiImpl) ListInvestedProduct(ctx context.Context, accessToken string, req *ListInvestedProductsRequest) (*ListInvestedProductsResponse, error) {
+	body, err := util.EncodeJsonRequest(req)
+	if err != nil {
+		return nil, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		"https://www.honestfund.kr/mypage/investor/investments/search",
-		util.EncodeJsonRequest(req),
+		body,
 	)
+	if err != nil {
+		return nil, err
+	}
 
+	httpReq = util.Retryable(httpReq)
 	addJsonContentType(httpReq)
 	addAccessTokenCookie(httpReq, accessToken)
 
-	res := util.HandleResponse(a.client.Do(httpReq))
-	defer res.Body.Close()
+	res, err := a.doWithSession(httpReq)
+	if err != nil {
+		return nil, err
+	}
 
 	data := &ListInvestedProductsResponse{}
-	util.DecodeJsonResponse(res, data)
+	if err := util.DecodeJsonResponse(res, data); err != nil {
+		return nil, err
+	}
 
-	return data
+	return data, nil
 }
 
 type ListInvestedProductsRequest struct {
@@ -159,7 +274,10 @@ type ListInvestedProductsResponse struct {
 	Code int
 	Data struct {
 		Investments []struct {
-			Title string
+			ProductUid   int
+			Title        string
+			Category     int
+			InvestAmount int
 		}
 		TotalInvestmentsCount int
 	}
@@ -172,3 +290,8 @@ func addJsonContentType(req *http.Request) {
 func addAccessTokenCookie(req *http.Request, accessToken string) {
 	req.AddCookie(&http.Cookie{Name: "accessToken", Value: accessToken})
 }
+
+func (a *ApiImpl) doWithSession(req *http.Request) (*http.Response, error) {
+	res, err := a.client.Do(req)
+	return util.HandleSessionResponse(res, err, "/login")
+}
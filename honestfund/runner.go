@@ -1,36 +1,120 @@
 package honestfund
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/session"
 	"strings"
 )
 
 type Runner struct {
-	accessToken string
-	service     Service
+	session          *session.Session
+	allowLaterRounds bool
+	service          Service
+	catalog          *autop2p.Catalog
 }
 
-func NewRunner(setting *autop2p.Setting, service Service) *Runner {
-	accessToken := service.Login(setting.Username, setting.Password)
+// OpenSession logs in as the setting's account, or reuses its cached session.
+// Settings of the same account share it, so a re-login for one does not
+// invalidate the others.
+func OpenSession(ctx context.Context, setting *autop2p.Setting, service Service, cache session.Cache) (*session.Session, error) {
+	return session.Open(ctx, cache, autop2p.Honestfund, setting.Username, func(ctx context.Context) (string, error) {
+		return service.Login(ctx, setting.Username, setting.Password)
+	})
+}
 
+func NewRunner(setting *autop2p.Setting, service Service, catalog *autop2p.Catalog, s *session.Session) *Runner {
 	return &Runner{
-		accessToken: accessToken,
-		service:     service,
+		session:          s,
+		allowLaterRounds: setting.AllowLaterRounds,
+		service:          service,
+		catalog:          catalog,
 	}
 }
 
-func (r *Runner) ListProducts() []autop2p.Product {
-	investedProductTitleSet := r.service.ListInvestedProductTitles(r.accessToken)
+// ListProducts takes the products from the run's catalog and leaves out later
+// rounds of products already invested in, unless allowLaterRounds is set, and
+// returns them as rejections.
+func (r *Runner) ListProducts(ctx context.Context) ([]autop2p.Product, []autop2p.Rejection, error) {
+	all, err := r.catalog.Products(ctx, autop2p.Honestfund)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.allowLaterRounds {
+		return all, nil, nil
+	}
+
+	var investedProductTitleSet map[string]struct{}
+	err = r.session.Do(ctx, func(token string) (err error) {
+		investedProductTitleSet, err = r.service.ListInvestedProductTitles(ctx, token)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
 
 	var products []autop2p.Product
-	for _, product := range r.service.ListProducts() {
-		if _, ok := investedProductTitleSet[strings.Trim(product.Title, " ")]; !ok {
-			products = append(products, product)
+	var rejections []autop2p.Rejection
+	for _, product := range all {
+		title := strings.Trim(product.Title, " ")
+		if _, ok := investedProductTitleSet[title]; ok {
+			rejections = append(rejections, autop2p.Rejection{
+				Product: product,
+				Reasons: []autop2p.Reason{{
+					Code:   autop2p.AlreadyInvested,
+					Detail: fmt.Sprintf("already invested in %q", title),
+				}},
+			})
+			continue
 		}
+		products = append(products, product)
 	}
-	return products
+	return products, rejections, nil
+}
+
+func (r *Runner) LoadDetail(ctx context.Context, product *autop2p.Product) error {
+	return r.service.GetProductDetail(ctx, product)
+}
+
+func (r *Runner) CheckProduct(ctx context.Context, product *autop2p.Product, amount int) error {
+	return r.session.Do(ctx, func(token string) error {
+		return r.service.CheckInvestment(ctx, token, product.Id, amount)
+	})
+}
+
+func (r *Runner) ListHoldings(ctx context.Context) ([]autop2p.Holding, error) {
+	var holdings []autop2p.Holding
+	err := r.session.Do(ctx, func(token string) (err error) {
+		holdings, err = r.service.ListHoldings(ctx, token)
+		return err
+	})
+	return holdings, err
+}
+
+// Balance asks with the first open product of the run's catalog, since the
+// balance only comes with an invest check.
+func (r *Runner) Balance(ctx context.Context) (int, error) {
+	products, err := r.catalog.Products(ctx, autop2p.Honestfund)
+	if err != nil {
+		return 0, err
+	}
+	if len(products) == 0 {
+		return 0, errors.New("no open product to query balance with")
+	}
+
+	var balance int
+	err = r.session.Do(ctx, func(token string) (err error) {
+		balance, err = r.service.GetBalance(ctx, token, products[0].Id)
+		return err
+	})
+	return balance, err
 }
 
-func (r *Runner) InvestProduct(product *autop2p.Product, amount int) *autop2p.InvestError {
-	return r.service.CheckAndInvest(r.accessToken, product.Id, amount)
+func (r *Runner) InvestProduct(ctx context.Context, product *autop2p.Product, amount int) error {
+	return r.session.Do(ctx, func(token string) error {
+		return r.service.CheckAndInvest(ctx, token, product.Id, amount)
+	})
 }
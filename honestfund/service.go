@@ -1,39 +1,85 @@
 package honestfund
 
 import (
+	"context"
 	"encoding/json"
+	"fmt"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Service interface {
-	ListProducts() []autop2p.Product
-	Login(email string, password string) string
-	CheckAndInvest(accessToken string, productId string, amount int) *autop2p.InvestError
-	ListInvestedProductTitles(accessToken string) map[string]struct{}
+	ListProducts(ctx context.Context) ([]autop2p.Product, error)
+	GetProductDetail(ctx context.Context, product *autop2p.Product) error
+	Login(ctx context.Context, email string, password string) (string, error)
+	CheckInvestment(ctx context.Context, accessToken string, productId string, amount int) error
+	CheckAndInvest(ctx context.Context, accessToken string, productId string, amount int) error
+	ListInvestedProductTitles(ctx context.Context, accessToken string) (map[string]struct{}, error)
+	ListHoldings(ctx context.Context, accessToken string) ([]autop2p.Holding, error)
+	// GetBalance reads the balance from the invest check of productId, which
+	// must be open.
+	GetBalance(ctx context.Context, accessToken string, productId string) (int, error)
 }
 
+const (
+	defaultPageSize = 50
+	defaultMaxPages = 20
+)
+
 type ServiceImpl struct {
-	api Api
+	api    Api
+	paging autop2p.PagingConf
 }
 
-func NewService(api Api) Service {
-	return &ServiceImpl{api}
+func NewService(api Api, paging autop2p.PagingConf) Service {
+	if paging.PageSize == 0 {
+		paging.PageSize = defaultPageSize
+	}
+	if paging.MaxPages == 0 {
+		paging.MaxPages = defaultMaxPages
+	}
+	return &ServiceImpl{api, paging}
 }
 
-func (s *ServiceImpl) ListProducts() []autop2p.Product {
-	resp := s.api.ListProducts(&ListProductRequest{
-		Category:     []string{},
-		PageSize:     50,
-		Scroll:       false,
-		State:        []int{2},
-		Tendency:     []string{},
-		TitleKeyword: "",
-	})
+// ListProducts walks the pages until one comes back short or adds nothing new.
+// Products shift between pages while walking, so they are deduplicated by id.
+func (s *ServiceImpl) ListProducts(ctx context.Context) ([]autop2p.Product, error) {
+	var products []autop2p.Product
+	seen := make(map[string]struct{})
+
+	for page := 0; page < s.paging.MaxPages; page++ {
+		resp, err := s.api.ListProducts(ctx, &ListProductRequest{
+			Category:     []string{},
+			Index:        page * s.paging.PageSize,
+			PageSize:     s.paging.PageSize,
+			Scroll:       false,
+			State:        []int{2},
+			Tendency:     []string{},
+			TitleKeyword: "",
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, p := range convertToProducts(resp) {
+			if _, ok := seen[p.Id]; ok {
+				continue
+			}
+			seen[p.Id] = struct{}{}
+			products = append(products, p)
+			added++
+		}
 
-	return convertToProducts(resp)
+		if len(resp.Data.Products) < s.paging.PageSize || added == 0 {
+			break
+		}
+	}
+	return products, nil
 }
 
 func convertToProducts(res *ListProductResponse) []autop2p.Product {
@@ -45,13 +91,59 @@ func convertToProducts(res *ListProductResponse) []autop2p.Product {
 			Rate:         p.Rate,
 			Period:       p.Period,
 			Company:      autop2p.Honestfund,
-			RemainAmount: int(float64(p.GoalAmount) * (100 - p.ProgressPercentage)),
+			RemainAmount: int(float64(p.GoalAmount) * (100 - p.ProgressPercentage) / 100),
 			Category:     convertCategory(p.Category),
+			Borrower:     normalizeTitle(p.TitleWithoutSeq),
+			GoalAmount:   p.GoalAmount,
+			Progress:     p.ProgressPercentage,
 		}
 	}
 	return products
 }
 
+func (s *ServiceImpl) GetProductDetail(ctx context.Context, product *autop2p.Product) error {
+	resp, err := s.api.GetProductDetail(ctx, product.Id)
+	if err != nil {
+		return err
+	}
+
+	detail := resp.Data.Product
+	if strconv.Itoa(detail.Uid) != product.Id {
+		return &autop2p.LayoutError{
+			Company: autop2p.Honestfund,
+			Reason:  fmt.Sprintf("product detail for %s returned product %d", product.Id, detail.Uid),
+		}
+	}
+
+	closingAt, err := parseTime(detail.RecruitEndDate)
+	if err != nil {
+		return &autop2p.LayoutError{
+			Company: autop2p.Honestfund,
+			Reason:  "can't parse recruitEndDate from product detail",
+			Err:     err,
+		}
+	}
+
+	product.LTV = detail.Ltv
+	product.Grade = detail.Grade
+	product.CollateralType = detail.CollateralType
+	product.CollateralValue = detail.CollateralValue
+	if detail.BorrowerUid != 0 {
+		product.BorrowerId = strconv.Itoa(detail.BorrowerUid)
+	}
+	product.GoalAmount = detail.GoalAmount
+	product.Progress = detail.ProgressPercentage
+	product.ClosingAt = closingAt
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	return time.ParseInLocation("2006-01-02T15:04:05", value, util.KST)
+}
+
 func convertCategory(category int) autop2p.Category {
 	switch category {
 	case 1:
@@ -67,45 +159,103 @@ func convertCategory(category int) autop2p.Category {
 	}
 }
 
-func (s *ServiceImpl) Login(email string, password string) string {
-	return s.api.Login(email, password)
+func (s *ServiceImpl) Login(ctx context.Context, email string, password string) (string, error) {
+	return s.api.Login(ctx, email, password)
 }
 
-func (s *ServiceImpl) CheckAndInvest(accessToken string, productId string, amount int) *autop2p.InvestError {
-	err := s.checkInvestment(accessToken, productId, amount)
+func (s *ServiceImpl) CheckAndInvest(ctx context.Context, accessToken string, productId string, amount int) error {
+	err := s.CheckInvestment(ctx, accessToken, productId, amount)
 	if err != nil {
 		return err
 	}
 	productUid, _ := strconv.Atoi(productId)
-	s.api.Invest(accessToken, &InvestRequest{
+	res, err := s.api.Invest(ctx, accessToken, &InvestRequest{
 		ProductUid:   productUid,
 		InvestAmount: amount,
 	})
-	return nil
+
+	var outcome error
+	if err == nil && res.Code != successCode {
+		outcome = autop2p.ParseInvestMessage(res.Message, investMessageCodes)
+	}
+	return autop2p.ConfirmInvestment(ctx, productId, err, outcome, func(ctx context.Context) ([]autop2p.Holding, error) {
+		return s.ListHoldings(ctx, accessToken)
+	})
 }
 
-func (s *ServiceImpl) checkInvestment(accessToken string, productId string, amount int) *autop2p.InvestError {
-	data := s.api.GetInvestConfirmHtml(accessToken, productId, amount)
+const successCode = 200
 
-	matcher, _ := regexp.Compile("app\\.constant\\('preload', (.+)\\)")
+// investMessageCodes classifies refusal messages by keyword. Capacity and
+// closing come first, since a closed product's message like "이미 마감된 상품"
+// also has the duplicate keyword.
+var investMessageCodes = []autop2p.MessageCode{
+	{Keyword: "마감", Code: autop2p.InsufficientCapacity},
+	{Keyword: "모집", Code: autop2p.InsufficientCapacity},
+	{Keyword: "잔여", Code: autop2p.InsufficientCapacity},
+	{Keyword: "예치금", Code: autop2p.InsufficientBalance},
+	{Keyword: "잔액", Code: autop2p.InsufficientBalance},
+	{Keyword: "한도", Code: autop2p.LimitExceeded},
+	{Keyword: "이미", Code: autop2p.Duplicated},
+	{Keyword: "중복", Code: autop2p.Duplicated},
+}
 
-	info := &PreloadInvest{}
-	if err := json.Unmarshal(matcher.FindSubmatch(data)[1], info); err != nil {
-		panic(err)
+func (s *ServiceImpl) CheckInvestment(ctx context.Context, accessToken string, productId string, amount int) error {
+	info, err := s.getPreloadInvest(ctx, accessToken, productId, amount)
+	if err != nil {
+		return err
 	}
 
 	if info.Invest.InvestedAmount != 0 {
 		return &autop2p.InvestError{Code: autop2p.Duplicated}
 	}
+	available := min(info.Account.Balance, info.Account.MaxInvestAmount)
 	if info.Account.Balance < amount {
-		return &autop2p.InvestError{Code: autop2p.InsufficientBalance}
+		return &autop2p.InvestError{Code: autop2p.InsufficientBalance, Available: available}
 	}
 	if info.Account.MaxInvestAmount < amount {
-		return &autop2p.InvestError{Code: autop2p.InsufficientCapacity}
+		return &autop2p.InvestError{Code: autop2p.InsufficientCapacity, Available: available}
 	}
 	return nil
 }
 
+func (s *ServiceImpl) GetBalance(ctx context.Context, accessToken string, productId string) (int, error) {
+
+	info, err := s.getPreloadInvest(ctx, accessToken, productId, minInvestAmount)
+	if err != nil {
+		return 0, err
+	}
+	return info.Account.Balance, nil
+}
+
+const minInvestAmount = 10000
+
+func (s *ServiceImpl) getPreloadInvest(ctx context.Context, accessToken string, productId string, amount int) (*PreloadInvest, error) {
+	data, err := s.api.GetInvestConfirmHtml(ctx, accessToken, productId, amount)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, _ := regexp.Compile("app\\.constant\\('preload', (.+)\\)")
+
+	match := matcher.FindSubmatch(data)
+	if match == nil {
+		return nil, &autop2p.LayoutError{
+			Company: autop2p.Honestfund,
+			Reason:  "can't find preload constant from invest confirm page",
+		}
+	}
+
+	info := &PreloadInvest{}
+	if err := json.Unmarshal(match[1], info); err != nil {
+		return nil, &autop2p.LayoutError{
+			Company: autop2p.Honestfund,
+			Reason:  "can't parse preload constant from invest confirm page",
+			Err:     err,
+		}
+	}
+	return info, nil
+}
+
 type PreloadInvest struct {
 	Account struct {
 		Balance int
@@ -116,16 +266,55 @@ type PreloadInvest struct {
 	}
 }
 
-func (s *ServiceImpl) ListInvestedProductTitles(accessToken string) map[string]struct{} {
-	index, pageSize := 0, 25
-	totalCount := pageSize + 1
-
+func (s *ServiceImpl) ListInvestedProductTitles(ctx context.Context, accessToken string) (map[string]struct{}, error) {
 	container := make(map[string]struct{})
 
-	matcher, _ := regexp.Compile("(\\s+(\\d+호))?(\\s+(\\d+차))?$")
+	err := s.listInvestments(ctx, accessToken, func(res *ListInvestedProductsResponse) {
+		for _, i := range res.Data.Investments {
+			container[normalizeTitle(i.Title)] = struct{}{}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return container, nil
+}
+
+func (s *ServiceImpl) ListHoldings(ctx context.Context, accessToken string) ([]autop2p.Holding, error) {
+	var holdings []autop2p.Holding
+
+	err := s.listInvestments(ctx, accessToken, func(res *ListInvestedProductsResponse) {
+		for _, i := range res.Data.Investments {
+			holdings = append(holdings, autop2p.Holding{
+				ProductId: strconv.Itoa(i.ProductUid),
+				Title:     i.Title,
+				Category:  convertCategory(i.Category),
+				Amount:    i.InvestAmount,
+				Borrower:  normalizeTitle(i.Title),
+			})
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return holdings, nil
+}
+
+var titleSeqMatcher = regexp.MustCompile("(\\s+(\\d+호))?(\\s+(\\d+차))?$")
+
+func normalizeTitle(title string) string {
+	if strings.HasPrefix(title, "SCF") {
+		return strings.Trim(title, " ")
+	}
+	return strings.Trim(titleSeqMatcher.ReplaceAllString(title, ""), " ")
+}
+
+func (s *ServiceImpl) listInvestments(ctx context.Context, accessToken string, handle func(res *ListInvestedProductsResponse)) error {
+	index, pageSize := 0, 25
+	totalCount := pageSize + 1
 
 	for totalCount > index*pageSize {
-		res := s.api.ListInvestedProduct(accessToken, &ListInvestedProductsRequest{
+		res, err := s.api.ListInvestedProduct(ctx, accessToken, &ListInvestedProductsRequest{
 			Category:     -1,
 			Index:        index * pageSize,
 			InvestState:  nil,
@@ -133,17 +322,14 @@ func (s *ServiceImpl) ListInvestedProductTitles(accessToken string) map[string]s
 			PageSize:     pageSize,
 			TitleKeyword: "",
 		})
-
-		for _, i := range res.Data.Investments {
-			if strings.HasPrefix(i.Title, "SCF") {
-				container[strings.Trim(i.Title, " ")] = struct{}{}
-			} else {
-				container[strings.Trim(matcher.ReplaceAllString(i.Title, ""), " ")] = struct{}{}
-			}
+		if err != nil {
+			return err
 		}
 
+		handle(res)
+
 		totalCount = res.Data.TotalInvestmentsCount
 		index += 1
 	}
-	return container
+	return nil
 }
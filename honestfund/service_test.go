@@ -1,39 +1,50 @@
 package honestfund
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 type ApiMock struct {
 	mock.Mock
 }
 
-func (m *ApiMock) ListProducts(req *ListProductRequest) *ListProductResponse {
-	args := m.Called(req)
-	return args.Get(0).(*ListProductResponse)
+func (m *ApiMock) ListProducts(ctx context.Context, req *ListProductRequest) (*ListProductResponse, error) {
+	args := m.Called(ctx, req)
+	return args.Get(0).(*ListProductResponse), args.Error(1)
 }
 
-func (m *ApiMock) Login(email string, password string) string {
-	args := m.Called(email, password)
-	return args.Get(0).(string)
+func (m *ApiMock) GetProductDetail(ctx context.Context, productId string) (*ProductDetailResponse, error) {
+	args := m.Called(ctx, productId)
+	return args.Get(0).(*ProductDetailResponse), args.Error(1)
 }
 
-func (m *ApiMock) Invest(accessToken string, req *InvestRequest) {
-	m.Called(accessToken, req)
+func (m *ApiMock) Login(ctx context.Context, email string, password string) (string, error) {
+	args := m.Called(ctx, email, password)
+	return args.Get(0).(string), args.Error(1)
 }
 
-func (m *ApiMock) GetInvestConfirmHtml(accessToken string, productId string, amount int) []byte {
-	args := m.Called(accessToken, productId, amount)
-	return args.Get(0).([]byte)
+func (m *ApiMock) Invest(ctx context.Context, accessToken string, req *InvestRequest) (*InvestResponse, error) {
+	args := m.Called(ctx, accessToken, req)
+	res, _ := args.Get(0).(*InvestResponse)
+	return res, args.Error(1)
 }
 
-func (m *ApiMock) ListInvestedProduct(accessToken string, req *ListInvestedProductsRequest) *ListInvestedProductsResponse {
-	args := m.Called(accessToken, req)
-	return args.Get(0).(*ListInvestedProductsResponse)
+func (m *ApiMock) GetInvestConfirmHtml(ctx context.Context, accessToken string, productId string, amount int) ([]byte, error) {
+	args := m.Called(ctx, accessToken, productId, amount)
+	return args.Get(0).([]byte), args.Error(1)
+}
+
+func (m *ApiMock) ListInvestedProduct(ctx context.Context, accessToken string, req *ListInvestedProductsRequest) (*ListInvestedProductsResponse, error) {
+	args := m.Called(ctx, accessToken, req)
+	return args.Get(0).(*ListInvestedProductsResponse), args.Error(1)
 }
 
 func TestServiceImpl_ListProducts(t *testing.T) {
@@ -68,17 +79,18 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 	}
 
 	mockApi := &ApiMock{}
-	mockApi.On("ListProducts", &ListProductRequest{
+	mockApi.On("ListProducts", mock.Anything, &ListProductRequest{
 		Category:     []string{},
 		PageSize:     50,
 		Scroll:       false,
 		State:        []int{2},
 		Tendency:     []string{},
 		TitleKeyword: "",
-	}).Return(resp)
+	}).Return(resp, nil)
 
-	s := NewService(mockApi)
-	p := s.ListProducts()
+	s := NewService(mockApi, autop2p.PagingConf{})
+	p, err := s.ListProducts(context.Background())
+	assert.Nil(t, err)
 	assert.Len(t, p, 2)
 	assert.Contains(t, p, autop2p.Product{
 		Id:           "12384",
@@ -86,8 +98,11 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 		Title:        "SCF 플러스",
 		Rate:         6.5,
 		Period:       2,
-		RemainAmount: 45000000000,
+		RemainAmount: 450000000,
 		Category:     autop2p.CorporateCredit,
+		Borrower:     "SCF 플러스",
+		GoalAmount:   500000000,
+		Progress:     10,
 	})
 	assert.Contains(t, p, autop2p.Product{
 		Id:           "12383",
@@ -95,24 +110,144 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 		Title:        "여수 마리나항만 프리미엄 생활형숙박시설 신축",
 		Rate:         13,
 		Period:       3,
-		RemainAmount: 2000000000,
+		RemainAmount: 20000000,
 		Category:     autop2p.PF,
+		Borrower:     "여수 마리나항만 프리미엄 생활형숙박시설 신축",
+		GoalAmount:   100000000,
+		Progress:     80,
 	})
 }
 
+func productPage(uids ...int) *ListProductResponse {
+	resp := &ListProductResponse{}
+	for _, uid := range uids {
+		resp.Data.Products = append(resp.Data.Products, struct {
+			Uid                int
+			TitleWithoutSeq    string
+			Rate               float64
+			Period             int
+			GoalAmount         int
+			ProgressPercentage float64
+			Category           int
+		}{Uid: uid})
+	}
+	return resp
+}
+
+func pageRequest(index int, pageSize int) *ListProductRequest {
+	return &ListProductRequest{
+		Category:     []string{},
+		Index:        index,
+		PageSize:     pageSize,
+		Scroll:       false,
+		State:        []int{2},
+		Tendency:     []string{},
+		TitleKeyword: "",
+	}
+}
+
+func TestServiceImpl_ListProducts_Pagination(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("ListProducts", mock.Anything, pageRequest(0, 2)).Return(productPage(1, 2), nil)
+	mockApi.On("ListProducts", mock.Anything, pageRequest(2, 2)).Return(productPage(2, 3), nil)
+	mockApi.On("ListProducts", mock.Anything, pageRequest(4, 2)).Return(productPage(4), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{PageSize: 2})
+	p, err := s.ListProducts(context.Background())
+
+	assert.Nil(t, err)
+	var ids []string
+	for _, product := range p {
+		ids = append(ids, product.Id)
+	}
+	assert.Equal(t, []string{"1", "2", "3", "4"}, ids)
+	mockApi.AssertNumberOfCalls(t, "ListProducts", 3)
+}
+
+func TestServiceImpl_ListProducts_MaxPages(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("ListProducts", mock.Anything, pageRequest(0, 2)).Return(productPage(1, 2), nil)
+	mockApi.On("ListProducts", mock.Anything, pageRequest(2, 2)).Return(productPage(3, 4), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{PageSize: 2, MaxPages: 2})
+	p, err := s.ListProducts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, p, 4)
+	mockApi.AssertNumberOfCalls(t, "ListProducts", 2)
+}
+
+func TestServiceImpl_GetProductDetail(t *testing.T) {
+	jsonString := `{
+	  "code": 200,
+	  "data": {
+		"product": {
+		  "uid": 12383,
+		  "ltv": 68.5,
+		  "grade": "A2",
+		  "collateralType": "아파트",
+		  "collateralValue": 450000000,
+		  "borrowerUid": 991,
+		  "goalAmount": 100000000,
+		  "progressPercentage": 80,
+		  "recruitEndDate": "2021-06-01T18:00:00"
+		}
+	  }
+	}`
+	resp := &ProductDetailResponse{}
+	if err := json.Unmarshal([]byte(jsonString), resp); err != nil {
+		panic(err)
+	}
+
+	mockApi := &ApiMock{}
+	mockApi.On("GetProductDetail", mock.Anything, "12383").Return(resp, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	p := &autop2p.Product{Id: "12383"}
+	err := s.GetProductDetail(context.Background(), p)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &autop2p.Product{
+		Id:              "12383",
+		LTV:             68.5,
+		Grade:           "A2",
+		CollateralType:  "아파트",
+		CollateralValue: 450000000,
+		BorrowerId:      "991",
+		GoalAmount:      100000000,
+		Progress:        80,
+		ClosingAt:       time.Date(2021, 6, 1, 18, 0, 0, 0, util.KST),
+	}, p)
+}
+
+func TestServiceImpl_GetProductDetail_LayoutChanged(t *testing.T) {
+	detail := &ProductDetailResponse{}
+	detail.Data.Product.Uid = 12383
+	detail.Data.Product.RecruitEndDate = "6월 1일 18시"
+
+	mockApi := &ApiMock{}
+	mockApi.On("GetProductDetail", mock.Anything, "12383").Return(detail, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.GetProductDetail(context.Background(), &autop2p.Product{Id: "12383"})
+
+	assert.IsType(t, &autop2p.LayoutError{}, err)
+}
+
 func TestServiceImpl_Login(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("Login", "email", "password").Return("ACCESS_TOKEN")
+	mockApi.On("Login", mock.Anything, "email", "password").Return("ACCESS_TOKEN", nil)
 
-	s := NewService(mockApi)
-	accessToken := s.Login("email", "password")
+	s := NewService(mockApi, autop2p.PagingConf{})
+	accessToken, err := s.Login(context.Background(), "email", "password")
 
+	assert.Nil(t, err)
 	assert.Equal(t, accessToken, "ACCESS_TOKEN")
 }
 
 func TestServiceImpl_CheckAndInvest_Duplicated(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("GetInvestConfirmHtml", "accessToken", "1", 10000).Return([]byte(`
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
 		<!DOCTYPE html>
 		<html lang="ko" ng-app="app">
 		<head></head>
@@ -125,17 +260,17 @@ func TestServiceImpl_CheckAndInvest_Duplicated(t *testing.T) {
 		  </div>
 		</body>
 		</html>
-   `))
+   `), nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("accessToken", "1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckAndInvest(context.Background(), "accessToken", "1", 10000)
 
-	assert.Equal(t, err.Code, autop2p.Duplicated)
+	assert.Equal(t, &autop2p.InvestError{Code: autop2p.Duplicated}, err)
 }
 
 func TestServiceImpl_CheckAndInvest_InsufficientBalance(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("GetInvestConfirmHtml", "accessToken", "1", 10000).Return([]byte(`
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
 		<!DOCTYPE html>
 		<html lang="ko" ng-app="app">
 		<head></head>
@@ -148,17 +283,17 @@ func TestServiceImpl_CheckAndInvest_InsufficientBalance(t *testing.T) {
 		  </div>
 		</body>
 		</html>
-   `))
+   `), nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("accessToken", "1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckAndInvest(context.Background(), "accessToken", "1", 10000)
 
-	assert.Equal(t, err.Code, autop2p.InsufficientBalance)
+	assert.Equal(t, &autop2p.InvestError{Code: autop2p.InsufficientBalance, Available: 1000}, err)
 }
 
 func TestServiceImpl_CheckAndInvest_InsufficientCapacity(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("GetInvestConfirmHtml", "accessToken", "1", 10000).Return([]byte(`
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
 		<!DOCTYPE html>
 		<html lang="ko" ng-app="app">
 		<head></head>
@@ -171,17 +306,17 @@ func TestServiceImpl_CheckAndInvest_InsufficientCapacity(t *testing.T) {
 		  </div>
 		</body>
 		</html>
-   `))
+   `), nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("accessToken", "1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckAndInvest(context.Background(), "accessToken", "1", 10000)
 
-	assert.Equal(t, err.Code, autop2p.InsufficientCapacity)
+	assert.Equal(t, &autop2p.InvestError{Code: autop2p.InsufficientCapacity}, err)
 }
 
 func TestServiceImpl_CheckAndInvest(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("GetInvestConfirmHtml", "accessToken", "1", 10000).Return([]byte(`
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
 		<!DOCTYPE html>
 		<html lang="ko" ng-app="app">
 		<head></head>
@@ -194,15 +329,117 @@ func TestServiceImpl_CheckAndInvest(t *testing.T) {
 		  </div>
 		</body>
 		</html>
-   `))
-	mockApi.On("Invest", "accessToken", mock.Anything).Return()
+   `), nil)
+	mockApi.On("Invest", mock.Anything, "accessToken", mock.Anything).Return(&InvestResponse{Code: 200}, nil)
+	mockApi.On("ListInvestedProduct", mock.Anything, "accessToken", mock.Anything).Return(investedPage(1), nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("accessToken", "1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckAndInvest(context.Background(), "accessToken", "1", 10000)
 
 	assert.Nil(t, err)
 }
 
+func investedPage(productUids ...int) *ListInvestedProductsResponse {
+	page := &ListInvestedProductsResponse{}
+	for _, uid := range productUids {
+		page.Data.Investments = append(page.Data.Investments, struct {
+			ProductUid   int
+			Title        string
+			Category     int
+			InvestAmount int
+		}{ProductUid: uid})
+	}
+	page.Data.TotalInvestmentsCount = len(productUids)
+	return page
+}
+
+func TestServiceImpl_CheckAndInvest_Confirmation(t *testing.T) {
+	preload := []byte(`app.constant('preload', {"account":{"balance":10000,"maxInvestAmount":10000},"invest":{"investedAmount":null}})`)
+
+	tests := []struct {
+		name      string
+		res       *InvestResponse
+		investErr error
+		holdings  *ListInvestedProductsResponse
+		code      string
+		err       string
+	}{
+		{
+			name:     "soft failure",
+			res:      &InvestResponse{Code: 400, Message: "투자 한도를 초과했습니다."},
+			holdings: investedPage(),
+			code:     autop2p.LimitExceeded,
+			err:      "investor limit exceeded: 투자 한도를 초과했습니다.",
+		},
+		{
+			name:     "unknown message",
+			res:      &InvestResponse{Code: 500, Message: "잠시 후 다시 시도해주세요."},
+			holdings: investedPage(2),
+			code:     autop2p.Rejected,
+			err:      "investment rejected: 잠시 후 다시 시도해주세요.",
+		},
+		{
+			name:     "success not in holdings",
+			res:      &InvestResponse{Code: 200},
+			holdings: investedPage(2),
+			code:     autop2p.Unconfirmed,
+			err:      "investment unconfirmed: response succeeded but product is not in holdings",
+		},
+		{
+			name:     "failure in holdings",
+			res:      &InvestResponse{Code: 400, Message: "이미 투자한 상품입니다."},
+			holdings: investedPage(1),
+			code:     autop2p.Unconfirmed,
+			err:      `investment unconfirmed: response failed with "duplicated investment: 이미 투자한 상품입니다." but product is in holdings`,
+		},
+		{
+			name:      "unreadable response in holdings",
+			investErr: &util.DecodeError{Url: "https://www.honestfund.kr/invest/confirm"},
+			holdings:  investedPage(1),
+		},
+		{
+			name:      "unreadable response not in holdings",
+			investErr: &util.DecodeError{Url: "https://www.honestfund.kr/invest/confirm"},
+			holdings:  investedPage(),
+			code:      autop2p.Unconfirmed,
+			err:       `investment unconfirmed: request failed with "failed to decode response from https://www.honestfund.kr/invest/confirm: <nil>" and product not in holdings`,
+		},
+		{
+			name:      "server error in holdings",
+			investErr: &util.StatusError{StatusCode: 502, Url: "https://www.honestfund.kr/invest/confirm"},
+			holdings:  investedPage(1),
+		},
+		{
+			name:      "timeout not in holdings",
+			investErr: context.DeadlineExceeded,
+			holdings:  investedPage(),
+			code:      autop2p.Unconfirmed,
+			err:       `investment unconfirmed: request failed with "context deadline exceeded" and product not in holdings`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockApi := &ApiMock{}
+			mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return(preload, nil)
+			mockApi.On("Invest", mock.Anything, "accessToken", mock.Anything).Return(tt.res, tt.investErr)
+			mockApi.On("ListInvestedProduct", mock.Anything, "accessToken", mock.Anything).Return(tt.holdings, nil)
+
+			s := NewService(mockApi, autop2p.PagingConf{})
+			err := s.CheckAndInvest(context.Background(), "accessToken", "1", 10000)
+
+			if tt.code == "" {
+				assert.Nil(t, err)
+				return
+			}
+			var investErr *autop2p.InvestError
+			assert.True(t, errors.As(err, &investErr))
+			assert.Equal(t, tt.code, investErr.Code)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
 func TestServiceImpl_ListInvestedProductTitles(t *testing.T) {
 	jsonString1 := `{
 	  "code": 200,
@@ -236,26 +473,27 @@ func TestServiceImpl_ListInvestedProductTitles(t *testing.T) {
 	}
 
 	mockApi := &ApiMock{}
-	mockApi.On("ListInvestedProduct", mock.Anything, &ListInvestedProductsRequest{
+	mockApi.On("ListInvestedProduct", mock.Anything, mock.Anything, &ListInvestedProductsRequest{
 		Category:     -1,
 		Index:        0,
 		InvestState:  nil,
 		IsOngoing:    true,
 		PageSize:     25,
 		TitleKeyword: "",
-	}).Return(page1)
-	mockApi.On("ListInvestedProduct", mock.Anything, &ListInvestedProductsRequest{
+	}).Return(page1, nil)
+	mockApi.On("ListInvestedProduct", mock.Anything, mock.Anything, &ListInvestedProductsRequest{
 		Category:     -1,
 		Index:        25,
 		InvestState:  nil,
 		IsOngoing:    true,
 		PageSize:     25,
 		TitleKeyword: "",
-	}).Return(page2)
+	}).Return(page2, nil)
 
-	s := NewService(mockApi)
-	ret := s.ListInvestedProductTitles("accessToken")
+	s := NewService(mockApi, autop2p.PagingConf{})
+	ret, err := s.ListInvestedProductTitles(context.Background(), "accessToken")
 
+	assert.Nil(t, err)
 	assert.Len(t, ret, 5)
 	assert.Contains(t, ret, "어펀")
 	assert.Contains(t, ret, "SCF 베이직 131호")
@@ -263,3 +501,91 @@ func TestServiceImpl_ListInvestedProductTitles(t *testing.T) {
 	assert.Contains(t, ret, "이 페이지에 25개가 들어있는 셈 치자")
 	assert.Contains(t, ret, "여수 마리나 항만")
 }
+
+func TestServiceImpl_CheckInvestment(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
+		<script>
+		app.constant('preload', {"account":{"balance":10000,"maxInvestAmount":10000},"invest":{"investedAmount":null}});
+		</script>
+   `), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckInvestment(context.Background(), "accessToken", "1", 10000)
+
+	assert.Nil(t, err)
+	mockApi.AssertNotCalled(t, "Invest", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestServiceImpl_CheckInvestment_LayoutChanged(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "1", 10000).Return([]byte(`
+		<script>
+		app.constant('initial', {});
+		</script>
+   `), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	err := s.CheckInvestment(context.Background(), "accessToken", "1", 10000)
+
+	var layoutErr *autop2p.LayoutError
+	assert.True(t, errors.As(err, &layoutErr))
+	assert.Equal(t, autop2p.Honestfund, layoutErr.Company)
+}
+
+func TestServiceImpl_ListHoldings(t *testing.T) {
+	jsonString := `{
+	  "code": 200,
+	  "data": {
+		"investments": [
+		  { "productUid": 1, "title": "어펀 1호 1차", "category": 2, "investAmount": 10000 },
+		  { "productUid": 2, "title": "SCF 베이직 131호", "category": 3, "investAmount": 20000 }
+		],
+		"totalInvestmentsCount": 2
+	  }
+	}`
+	page := &ListInvestedProductsResponse{}
+	if err := json.Unmarshal([]byte(jsonString), page); err != nil {
+		panic(err)
+	}
+
+	mockApi := &ApiMock{}
+	mockApi.On("ListInvestedProduct", mock.Anything, "accessToken", mock.Anything).Return(page, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	ret, err := s.ListHoldings(context.Background(), "accessToken")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []autop2p.Holding{
+		{ProductId: "1", Title: "어펀 1호 1차", Category: autop2p.MortgageRealEstate, Amount: 10000, Borrower: "어펀"},
+		{ProductId: "2", Title: "SCF 베이직 131호", Category: autop2p.CorporateCredit, Amount: 20000, Borrower: "SCF 베이직 131호"},
+	}, ret)
+}
+
+func TestServiceImpl_GetBalance(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("GetInvestConfirmHtml", mock.Anything, "accessToken", "12384", 10000).Return([]byte(`
+		<script>
+		app.constant('preload', {"account":{"balance":123000,"maxInvestAmount":10000},"invest":{"investedAmount":null}});
+		</script>
+   `), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{})
+	balance, err := s.GetBalance(context.Background(), "accessToken", "12384")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 123000, balance)
+}
+
+func TestInvestMessageCodes(t *testing.T) {
+	for message, code := range map[string]string{
+		"이미 마감된 상품입니다.":   autop2p.InsufficientCapacity,
+		"모집 금액을 초과했습니다.":  autop2p.InsufficientCapacity,
+		"이미 투자한 상품입니다.":   autop2p.Duplicated,
+		"예치금이 부족합니다.":     autop2p.InsufficientBalance,
+		"투자 한도를 초과했습니다.":  autop2p.LimitExceeded,
+		"잠시 후 다시 시도해주세요.": autop2p.Rejected,
+	} {
+		assert.Equal(t, code, autop2p.ParseInvestMessage(message, investMessageCodes).Code, message)
+	}
+}
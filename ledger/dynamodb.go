@@ -0,0 +1,100 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/feature/dynamodb/attributevalue"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb/types"
+	"time"
+)
+
+type DynamoDBApi interface {
+	PutItem(ctx context.Context, params *dynamodb.PutItemInput, optFns ...func(*dynamodb.Options)) (*dynamodb.PutItemOutput, error)
+	Query(ctx context.Context, params *dynamodb.QueryInput, optFns ...func(*dynamodb.Options)) (*dynamodb.QueryOutput, error)
+}
+
+type DynamoDBStore struct {
+	client DynamoDBApi
+	table  string
+}
+
+func NewDynamoDBStore(client DynamoDBApi, table string) *DynamoDBStore {
+	return &DynamoDBStore{client: client, table: table}
+}
+
+type dynamoDBItem struct {
+	Entry
+	Account string
+	Key     string
+}
+
+func accountKey(company autop2p.CompanyType, username string) string {
+	return fmt.Sprintf("%s#%s", company, username)
+}
+
+func (s *DynamoDBStore) Record(ctx context.Context, entry *Entry) error {
+	item, err := attributevalue.MarshalMap(&dynamoDBItem{
+		Entry:   *entry,
+		Account: accountKey(entry.Company, entry.Username),
+		Key:     fmt.Sprintf("%s#%s#%s", entry.Timestamp.UTC().Format(time.RFC3339Nano), entry.ProductId, entry.Result),
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.client.PutItem(ctx, &dynamodb.PutItemInput{
+		TableName: aws.String(s.table),
+		Item:      item,
+	})
+	return err
+}
+
+func (s *DynamoDBStore) List(ctx context.Context, company autop2p.CompanyType, username string) ([]Entry, error) {
+	var entries []Entry
+	var startKey map[string]types.AttributeValue
+	for {
+		out, err := s.client.Query(ctx, &dynamodb.QueryInput{
+			TableName:              aws.String(s.table),
+			KeyConditionExpression: aws.String("Account = :account"),
+			ExpressionAttributeValues: map[string]types.AttributeValue{
+				":account": &types.AttributeValueMemberS{Value: accountKey(company, username)},
+			},
+			ExclusiveStartKey: startKey,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		var items []dynamoDBItem
+		if err := attributevalue.UnmarshalListOfMaps(out.Items, &items); err != nil {
+			return nil, err
+		}
+		for _, item := range items {
+			entries = append(entries, item.Entry)
+		}
+
+		if len(out.LastEvaluatedKey) == 0 {
+			return entries, nil
+		}
+		startKey = out.LastEvaluatedKey
+	}
+}
+
+func CreateDynamoDBTable(ctx context.Context, client *dynamodb.Client, table string) error {
+	_, err := client.CreateTable(ctx, &dynamodb.CreateTableInput{
+		TableName: aws.String(table),
+		AttributeDefinitions: []types.AttributeDefinition{
+			{AttributeName: aws.String("Account"), AttributeType: types.ScalarAttributeTypeS},
+			{AttributeName: aws.String("Key"), AttributeType: types.ScalarAttributeTypeS},
+		},
+		KeySchema: []types.KeySchemaElement{
+			{AttributeName: aws.String("Account"), KeyType: types.KeyTypeHash},
+			{AttributeName: aws.String("Key"), KeyType: types.KeyTypeRange},
+		},
+		BillingMode: types.BillingModePayPerRequest,
+	})
+	return err
+}
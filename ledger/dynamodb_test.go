@@ -0,0 +1,64 @@
+package ledger
+
+import (
+	"context"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"testing"
+	"time"
+)
+
+// DYNAMODB_LOCAL_ENDPOINT=http://localhost:8000 go test ./ledger/
+func newLocalDynamoDBClient(t *testing.T) *dynamodb.Client {
+	endpoint := os.Getenv("DYNAMODB_LOCAL_ENDPOINT")
+	if endpoint == "" {
+		t.Skip("DYNAMODB_LOCAL_ENDPOINT is not set")
+	}
+
+	return dynamodb.New(dynamodb.Options{
+		Region:       "ap-northeast-2",
+		BaseEndpoint: aws.String(endpoint),
+		Credentials:  credentials.NewStaticCredentialsProvider("local", "local", ""),
+	})
+}
+
+func TestDynamoDBStore(t *testing.T) {
+	client := newLocalDynamoDBClient(t)
+	ctx := context.Background()
+
+	table := fmt.Sprintf("autop2p-ledger-%d", time.Now().UnixNano())
+	assert.Nil(t, CreateDynamoDBTable(ctx, client, table))
+	defer client.DeleteTable(ctx, &dynamodb.DeleteTableInput{TableName: aws.String(table)})
+
+	s := NewDynamoDBStore(client, table)
+
+	setting := &autop2p.Setting{Name: "pf", Company: autop2p.Peoplefund, Username: "username"}
+	product := &autop2p.Product{
+		Id:       "ml4980-1",
+		Company:  autop2p.Peoplefund,
+		Title:    "아파트 담보(투자시 부자동) 2144",
+		Rate:     9,
+		Period:   12,
+		Category: autop2p.MortgageRealEstate,
+	}
+	assert.Nil(t, s.Record(ctx, NewEntry(setting, product, 10000, Attempted, "")))
+	assert.Nil(t, s.Record(ctx, NewEntry(setting, product, 10000, Failed, autop2p.InsufficientBalance)))
+
+	entries, err := s.List(ctx, autop2p.Peoplefund, "username")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, Attempted, entries[0].Result)
+	assert.Equal(t, Failed, entries[1].Result)
+	assert.Equal(t, autop2p.InsufficientBalance, entries[1].Code)
+	assert.Equal(t, "ml4980-1", entries[1].ProductId)
+	assert.Equal(t, autop2p.MortgageRealEstate, entries[1].Category)
+
+	entries, err = s.List(ctx, autop2p.Honestfund, "username")
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+}
@@ -0,0 +1,47 @@
+package ledger
+
+import (
+	"context"
+	"github.com/Joddev/autop2p"
+	"github.com/stretchr/testify/assert"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileStore(t *testing.T) {
+	s := NewFileStore(filepath.Join(t.TempDir(), "ledger.jsonl"))
+	ctx := context.Background()
+
+	entries, err := s.List(ctx, autop2p.Honestfund, "username")
+	assert.Nil(t, err)
+	assert.Empty(t, entries)
+
+	setting := &autop2p.Setting{Name: "hf", Company: autop2p.Honestfund, Username: "username"}
+	product := &autop2p.Product{
+		Id:       "12384",
+		Company:  autop2p.Honestfund,
+		Title:    "SCF 플러스",
+		Rate:     6.5,
+		Period:   2,
+		Category: autop2p.CorporateCredit,
+	}
+	assert.Nil(t, s.Record(ctx, NewEntry(setting, product, 10000, Attempted, "")))
+	assert.Nil(t, s.Record(ctx, NewEntry(setting, product, 10000, Invested, "")))
+	assert.Nil(t, s.Record(ctx, NewEntry(
+		&autop2p.Setting{Company: autop2p.Peoplefund, Username: "username"}, product, 10000, Attempted, "",
+	)))
+
+	entries, err = s.List(ctx, autop2p.Honestfund, "username")
+	assert.Nil(t, err)
+	assert.Len(t, entries, 2)
+	assert.Equal(t, Attempted, entries[0].Result)
+	assert.Equal(t, Invested, entries[1].Result)
+	assert.Equal(t, "hf", entries[1].Setting)
+	assert.Equal(t, "12384", entries[1].ProductId)
+	assert.Equal(t, "SCF 플러스", entries[1].Title)
+	assert.Equal(t, 6.5, entries[1].Rate)
+	assert.Equal(t, 2, entries[1].Period)
+	assert.Equal(t, autop2p.CorporateCredit, entries[1].Category)
+	assert.Equal(t, 10000, entries[1].Amount)
+	assert.False(t, entries[1].Timestamp.IsZero())
+}
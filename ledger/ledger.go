@@ -0,0 +1,78 @@
+package ledger
+
+import (
+	"context"
+	"github.com/Joddev/autop2p"
+	"time"
+)
+
+const (
+	Attempted = "Attempted"
+	Invested  = "Invested"
+	Failed    = "Failed"
+	// Unconfirmed entries may have been invested, so they block retries like
+	// Attempted ones.
+	Unconfirmed = "Unconfirmed"
+)
+
+type Store interface {
+	Record(ctx context.Context, entry *Entry) error
+	List(ctx context.Context, company autop2p.CompanyType, username string) ([]Entry, error)
+}
+
+type Entry struct {
+	Setting   string
+	Company   autop2p.CompanyType
+	Username  string
+	ProductId string
+	Title     string
+	Rate      float64
+	Period    int
+	Category  autop2p.Category
+	Amount    int
+	Result    string
+	Code      string
+	Timestamp time.Time
+}
+
+func NewEntry(setting *autop2p.Setting, product *autop2p.Product, amount int, result string, code string) *Entry {
+	return &Entry{
+		Setting:   setting.Name,
+		Company:   setting.Company,
+		Username:  setting.Username,
+		ProductId: product.Id,
+		Title:     product.Title,
+		Rate:      product.Rate,
+		Period:    product.Period,
+		Category:  product.Category,
+		Amount:    amount,
+		Result:    result,
+		Code:      code,
+		Timestamp: time.Now(),
+	}
+}
+
+type NopStore struct{}
+
+func (s NopStore) Record(ctx context.Context, entry *Entry) error {
+	return nil
+}
+
+func (s NopStore) List(ctx context.Context, company autop2p.CompanyType, username string) ([]Entry, error) {
+	return nil, nil
+}
+
+func InvestedProductIds(entries []Entry) map[string]struct{} {
+	results := make(map[string]string)
+	for _, entry := range entries {
+		results[entry.ProductId] = entry.Result
+	}
+
+	ids := make(map[string]struct{})
+	for id, result := range results {
+		if result != Failed {
+			ids[id] = struct{}{}
+		}
+	}
+	return ids
+}
@@ -0,0 +1,28 @@
+package ledger
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestInvestedProductIds(t *testing.T) {
+	ids := InvestedProductIds([]Entry{
+		{ProductId: "1", Result: Attempted},
+		{ProductId: "1", Result: Invested},
+		{ProductId: "2", Result: Attempted},
+		{ProductId: "2", Result: Failed},
+		{ProductId: "3", Result: Attempted},
+		{ProductId: "4", Result: Attempted},
+		{ProductId: "4", Result: Failed},
+		{ProductId: "4", Result: Attempted},
+		{ProductId: "4", Result: Invested},
+		{ProductId: "5", Result: Attempted},
+		{ProductId: "5", Result: Unconfirmed},
+	})
+
+	assert.Len(t, ids, 4)
+	assert.Contains(t, ids, "1")
+	assert.Contains(t, ids, "3")
+	assert.Contains(t, ids, "4")
+	assert.Contains(t, ids, "5")
+}
@@ -0,0 +1,92 @@
+package autop2p
+
+type InvestorType string
+
+const (
+	GeneralInvestor         InvestorType = "general"
+	IncomeQualifiedInvestor InvestorType = "incomeQualified"
+	ProfessionalInvestor    InvestorType = "professional"
+)
+
+type Limit struct {
+	Total      int
+	RealEstate int
+	Borrower   int
+}
+
+func (t InvestorType) Limit() *Limit {
+	switch t {
+	case ProfessionalInvestor:
+		return nil
+	case IncomeQualifiedInvestor:
+		return &Limit{Total: 100000000, RealEstate: 100000000, Borrower: 20000000}
+	default:
+		return &Limit{Total: 30000000, RealEstate: 10000000, Borrower: 5000000}
+	}
+}
+
+type LimitChecker struct {
+	limit      *Limit
+	total      int
+	realEstate int
+	borrowers  []borrowerAmount
+}
+
+// borrowerAmount is an amount invested in a borrower known by id, by
+// normalized title, or both.
+type borrowerAmount struct {
+	id     string
+	title  string
+	amount int
+}
+
+func NewLimitChecker(investorType InvestorType, holdings []Holding) *LimitChecker {
+	c := &LimitChecker{limit: investorType.Limit()}
+	for _, h := range holdings {
+		c.add(h.Category, borrowerAmount{title: h.Borrower, amount: h.Amount})
+	}
+	return c
+}
+
+func (c *LimitChecker) Check(product *Product, amount int) error {
+	if c.limit == nil {
+		return nil
+	}
+	available := c.limit.Total - c.total
+	if product.Category.isRealState() {
+		available = min(available, c.limit.RealEstate-c.realEstate)
+	}
+	if product.BorrowerId != "" || product.Borrower != "" {
+		available = min(available, c.limit.Borrower-c.borrowerTotal(product))
+	}
+	if amount > available {
+		return &InvestError{Code: LimitExceeded, Available: max(available, 0)}
+	}
+	return nil
+}
+
+// borrowerTotal sums what is invested in the product's borrower. Holdings only
+// have titles, so an amount counts when either its id or its title matches.
+func (c *LimitChecker) borrowerTotal(product *Product) int {
+	total := 0
+	for _, b := range c.borrowers {
+		if product.BorrowerId != "" && b.id == product.BorrowerId || product.Borrower != "" && b.title == product.Borrower {
+			total += b.amount
+		}
+	}
+	return total
+}
+
+func (c *LimitChecker) Add(product *Product, amount int) {
+	c.add(product.Category, borrowerAmount{id: product.BorrowerId, title: product.Borrower, amount: amount})
+}
+
+func (c *LimitChecker) add(category Category, borrower borrowerAmount) {
+	c.total += borrower.amount
+	if category.isRealState() {
+		c.realEstate += borrower.amount
+	}
+	if borrower.id != "" || borrower.title != "" {
+		c.borrowers = append(c.borrowers, borrower)
+	}
+}
@@ -0,0 +1,64 @@
+package autop2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLimitChecker_Total(t *testing.T) {
+	c := NewLimitChecker(GeneralInvestor, []Holding{
+		{Category: CorporateCredit, Amount: 29990000, Borrower: "A"},
+	})
+
+	assert.Nil(t, c.Check(&Product{Category: PersonalCredit, Borrower: "B"}, 10000))
+	c.Add(&Product{Category: PersonalCredit, Borrower: "B"}, 10000)
+
+	assert.Equal(t, &InvestError{Code: LimitExceeded}, c.Check(&Product{Category: PersonalCredit, Borrower: "C"}, 10000))
+}
+
+func TestLimitChecker_RealEstate(t *testing.T) {
+	c := NewLimitChecker(GeneralInvestor, []Holding{
+		{Category: PF, Amount: 5000000, Borrower: "A"},
+		{Category: MortgageRealEstate, Amount: 5000000, Borrower: "B"},
+	})
+
+	assert.Equal(t, &InvestError{Code: LimitExceeded}, c.Check(&Product{Category: PF, Borrower: "C"}, 10000))
+	assert.Equal(t, &InvestError{Code: LimitExceeded}, c.Check(&Product{Category: MortgageRealEstate, Borrower: "C"}, 10000))
+	assert.Nil(t, c.Check(&Product{Category: CorporateCredit, Borrower: "C"}, 10000))
+}
+
+func TestLimitChecker_Borrower(t *testing.T) {
+	c := NewLimitChecker(IncomeQualifiedInvestor, []Holding{
+		{Category: CorporateCredit, Amount: 19990000, Borrower: "A"},
+	})
+
+	assert.Nil(t, c.Check(&Product{Category: CorporateCredit, Borrower: "A"}, 10000))
+	assert.Equal(t, &InvestError{Code: LimitExceeded, Available: 10000}, c.Check(&Product{Category: CorporateCredit, Borrower: "A"}, 20000))
+	assert.Nil(t, c.Check(&Product{Category: CorporateCredit, Borrower: "B"}, 20000))
+}
+
+func TestLimitChecker_BorrowerId(t *testing.T) {
+	c := NewLimitChecker(GeneralInvestor, []Holding{
+		{Category: CorporateCredit, Amount: 3000000, Borrower: "A"},
+	})
+	c.Add(&Product{Category: CorporateCredit, Borrower: "B", BorrowerId: "7"}, 1000000)
+
+	// Titles differ but the id matches, and the title still matches the holding.
+	assert.Equal(t, &InvestError{Code: LimitExceeded, Available: 1000000},
+		c.Check(&Product{Category: CorporateCredit, Borrower: "A", BorrowerId: "7"}, 2000000))
+	assert.Equal(t, &InvestError{Code: LimitExceeded, Available: 4000000},
+		c.Check(&Product{Category: CorporateCredit, Borrower: "C", BorrowerId: "7"}, 5000000))
+	assert.Nil(t, c.Check(&Product{Category: CorporateCredit, Borrower: "C", BorrowerId: "8"}, 5000000))
+}
+
+func TestLimitChecker_Professional(t *testing.T) {
+	c := NewLimitChecker(ProfessionalInvestor, []Holding{
+		{Category: PF, Amount: 1000000000, Borrower: "A"},
+	})
+
+	assert.Nil(t, c.Check(&Product{Category: PF, Borrower: "A"}, 1000000000))
+}
+
+func TestInvestorType_Limit_DefaultsToGeneral(t *testing.T) {
+	assert.Equal(t, GeneralInvestor.Limit(), InvestorType("").Limit())
+}
@@ -0,0 +1,470 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/session"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"text/tabwriter"
+)
+
+type command func(ctx context.Context, out *output, args []string) error
+
+var commands = map[string]command{
+	"run":      runAndPrint(false),
+	"plan":     runAndPrint(true),
+	"list":     listCommand,
+	"balance":  balanceCommand,
+	"history":  historyCommand,
+	"daemon":   daemonCommand,
+	"validate": validateCommand,
+	"explain":  explainCommand,
+}
+
+func runCommand(args []string) int {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	cmd, ok := commands[args[0]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n", args[0])
+		printUsage()
+		return 2
+	}
+
+	flags := flag.NewFlagSet(args[0], flag.ContinueOnError)
+	flags.StringVar(&confPath, "conf", confPath, "path to conf.yaml")
+	format := flags.String("o", "table", "output format (table, json)")
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	if *format != "table" && *format != "json" {
+		fmt.Fprintf(os.Stderr, "unsupported output format %q\n", *format)
+		return 2
+	}
+
+	logOutput = os.Stderr
+	session.LogOutput = os.Stderr
+	if err := cmd(ctx, &output{w: os.Stdout, json: *format == "json"}, flags.Args()); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func printUsage() {
+	fmt.Fprintln(os.Stderr, `usage: main <command> [-conf conf.yaml] [-o table|json] [args]
+
+commands:
+  run      invest according to conf.yaml
+  plan     list investments run would make without investing
+  list     list open products and which settings match them
+  balance  show cash balance of each account
+  history  show invested products of each account
+  daemon   keep running and invest on the schedules in conf.yaml
+  validate check conf.yaml and report every problem with its line and column
+  explain  show why each setting would or would not invest in each product,
+           limited to the product ids given as args`)
+}
+
+type output struct {
+	w    io.Writer
+	json bool
+}
+
+func (o *output) print(v interface{}, header string, row func(w io.Writer)) error {
+	if o.json {
+		encoder := json.NewEncoder(o.w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	}
+
+	w := tabwriter.NewWriter(o.w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, header)
+	row(w)
+	return w.Flush()
+}
+
+func runAndPrint(dryRun bool) command {
+	return func(ctx context.Context, out *output, args []string) error {
+		report, err := auto(ctx, dryRun)
+		if err != nil {
+			return err
+		}
+
+		err = out.print(report, "COMPANY\tUSERNAME\tID\tTITLE\tRATE\tPERIOD\tCATEGORY\tAMOUNT", func(w io.Writer) {
+			for _, i := range report.Investments {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f%%\t%d\t%s\t%d\n",
+					i.Company, i.Username, i.Product.Id, i.Product.Title,
+					i.Product.Rate, i.Product.Period, i.Product.Category, i.Amount)
+			}
+			for _, i := range report.Unconfirmed {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f%%\t%d\t%s\tUNCONFIRMED %d\n",
+					i.Company, i.Username, i.Product.Id, i.Product.Title,
+					i.Product.Rate, i.Product.Period, i.Product.Category, i.Amount)
+			}
+			for _, f := range report.Failures {
+				fmt.Fprintf(w, "%s\t%s\tFAILED\t%s\t\t\t\t\n", f.Company, f.Username, f.Error)
+			}
+			for _, r := range report.Rejections {
+				fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%.2f%%\t%d\t%s\tREJECTED %s\n",
+					r.Company, r.Username, r.Product.Id, r.Product.Title,
+					r.Product.Rate, r.Product.Period, r.Product.Category, reasonCodes(r.Reasons))
+			}
+		})
+		if err != nil {
+			return err
+		}
+
+		if len(report.Failures) > 0 {
+			return fmt.Errorf("%d settings failed", len(report.Failures))
+		}
+		return nil
+	}
+}
+
+type explainItem struct {
+	Company  autop2p.CompanyType
+	Username string
+	Setting  string
+	Product  autop2p.Product
+	Invest   bool
+	Reasons  []autop2p.Reason `json:",omitempty"`
+}
+
+// explainCommand does a dry run without notifying and shows the decision each
+// setting made on each product.
+func explainCommand(ctx context.Context, out *output, args []string) error {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return err
+	}
+
+	report, err := execute(ctx, conf, true)
+	if err != nil {
+		return err
+	}
+
+	ids := make(map[string]struct{})
+	for _, id := range args {
+		ids[id] = struct{}{}
+	}
+	wanted := func(p *autop2p.Product) bool {
+		_, ok := ids[p.Id]
+		return len(ids) == 0 || ok
+	}
+
+	var items []explainItem
+	for _, i := range report.Investments {
+		if wanted(&i.Product) {
+			items = append(items, explainItem{
+				Company:  i.Company,
+				Username: i.Username,
+				Setting:  i.Setting,
+				Product:  i.Product,
+				Invest:   true,
+			})
+		}
+	}
+	for _, r := range report.Rejections {
+		if wanted(&r.Product) {
+			items = append(items, explainItem{
+				Company:  r.Company,
+				Username: r.Username,
+				Setting:  r.Setting,
+				Product:  r.Product,
+				Reasons:  r.Reasons,
+			})
+		}
+	}
+
+	err = out.print(items, "SETTING\tCOMPANY\tID\tTITLE\tDECISION\tREASONS", func(w io.Writer) {
+		for _, i := range items {
+			decision := "INVEST"
+			if !i.Invest {
+				decision = "REJECT"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n",
+				i.Setting, i.Company, i.Product.Id, i.Product.Title, decision, reasonDetails(i.Reasons))
+		}
+		for _, f := range report.Failures {
+			fmt.Fprintf(w, "%s\t%s\t\t\tFAILED\t%s\n", f.Setting, f.Company, f.Error)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(report.Failures) > 0 {
+		return fmt.Errorf("%d settings failed", len(report.Failures))
+	}
+	return nil
+}
+
+func reasonCodes(reasons []autop2p.Reason) string {
+	codes := make([]string, len(reasons))
+	for i, r := range reasons {
+		codes[i] = r.Code
+	}
+	return strings.Join(codes, ",")
+}
+
+func reasonDetails(reasons []autop2p.Reason) string {
+	details := make([]string, len(reasons))
+	for i, r := range reasons {
+		details[i] = r.String()
+	}
+	return strings.Join(details, "; ")
+}
+
+type listItem struct {
+	Product autop2p.Product
+	Matches []string
+}
+
+func listCommand(ctx context.Context, out *output, args []string) error {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return err
+	}
+
+	var items []listItem
+	for _, company := range companies(conf.Settings) {
+		products, err := listOpenProducts(ctx, company)
+		if err != nil {
+			return err
+		}
+
+		for _, p := range products {
+			if needsDetail(conf.Settings, company, &p) {
+				if err := loadProductDetail(ctx, &p); err != nil {
+					return err
+				}
+			}
+
+			item := listItem{Product: p, Matches: []string{}}
+			for _, setting := range conf.Settings {
+				if setting.Company == company && setting.Match(&p) {
+					item.Matches = append(item.Matches, settingLabel(&setting))
+				}
+			}
+			items = append(items, item)
+		}
+	}
+
+	return out.print(items, "COMPANY\tID\tTITLE\tRATE\tPERIOD\tREMAIN\tCATEGORY\tMATCHES", func(w io.Writer) {
+		for _, i := range items {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%.2f%%\t%d\t%d\t%s\t%v\n",
+				i.Product.Company, i.Product.Id, i.Product.Title, i.Product.Rate,
+				i.Product.Period, i.Product.RemainAmount, i.Product.Category, i.Matches)
+		}
+	})
+}
+
+type balanceItem struct {
+	Company  autop2p.CompanyType
+	Username string
+	Balance  int
+	Error    string `json:",omitempty"`
+}
+
+func balanceCommand(ctx context.Context, out *output, args []string) error {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache, err := newSessionCache(&conf.Session)
+	if err != nil {
+		return err
+	}
+
+	var items []balanceItem
+	for _, setting := range accounts(conf.Settings) {
+		item := balanceItem{Company: setting.Company, Username: setting.Username}
+		err := withRunner(ctx, &setting, cache, func(runner autop2p.Runner) error {
+			balance, err := runner.Balance(ctx)
+			item.Balance = balance
+			return err
+		})
+		if err != nil {
+			item.Error = err.Error()
+		}
+		items = append(items, item)
+	}
+
+	return out.print(items, "COMPANY\tUSERNAME\tBALANCE\tERROR", func(w io.Writer) {
+		for _, i := range items {
+			fmt.Fprintf(w, "%s\t%s\t%d\t%s\n", i.Company, i.Username, i.Balance, i.Error)
+		}
+	})
+}
+
+type historyItem struct {
+	Company  autop2p.CompanyType
+	Username string
+	Holding  *autop2p.Holding `json:",omitempty"`
+	Error    string           `json:",omitempty"`
+}
+
+func historyCommand(ctx context.Context, out *output, args []string) error {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return err
+	}
+
+	cache, err := newSessionCache(&conf.Session)
+	if err != nil {
+		return err
+	}
+
+	var items []historyItem
+	for _, setting := range accounts(conf.Settings) {
+		err := withRunner(ctx, &setting, cache, func(runner autop2p.Runner) error {
+			holdings, err := runner.ListHoldings(ctx)
+			for i := range holdings {
+				items = append(items, historyItem{
+					Company:  setting.Company,
+					Username: setting.Username,
+					Holding:  &holdings[i],
+				})
+			}
+			return err
+		})
+		if err != nil {
+			items = append(items, historyItem{
+				Company:  setting.Company,
+				Username: setting.Username,
+				Error:    err.Error(),
+			})
+		}
+	}
+
+	return out.print(items, "COMPANY\tUSERNAME\tID\tTITLE\tCATEGORY\tAMOUNT\tERROR", func(w io.Writer) {
+		for _, i := range items {
+			if i.Holding == nil {
+				fmt.Fprintf(w, "%s\t%s\t\t\t\t\t%s\n", i.Company, i.Username, i.Error)
+				continue
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%d\t\n",
+				i.Company, i.Username, i.Holding.ProductId, i.Holding.Title, i.Holding.Category, i.Holding.Amount)
+		}
+	})
+}
+
+func validateCommand(ctx context.Context, out *output, args []string) error {
+	yamlFile, err := ioutil.ReadFile(confPath)
+	if err != nil {
+		return err
+	}
+
+	_, err = autop2p.LoadConf(yamlFile)
+
+	var errs autop2p.ValidationErrors
+	if err != nil && !errors.As(err, &errs) {
+		return confError(err)
+	}
+
+	items := []*autop2p.ValidationError(errs)
+	if items == nil {
+		items = []*autop2p.ValidationError{}
+	}
+	err = out.print(items, "LINE\tCOLUMN\tPATH\tMESSAGE", func(w io.Writer) {
+		for _, e := range items {
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\n", e.Line, e.Column, e.Path, e.Message)
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%s: %d problems", confPath, len(errs))
+	}
+	fmt.Fprintf(logOutput, "%s: OK\n", confPath)
+	return nil
+}
+
+func withRunner(ctx context.Context, setting *autop2p.Setting, cache session.Cache, fn func(runner autop2p.Runner) error) error {
+	s, err := openSession(ctx, setting, cache)
+	if err != nil {
+		return err
+	}
+	runner, err := newRunner(setting, autop2p.NewCatalog(listOpenProducts), s)
+	if err != nil {
+		return err
+	}
+	return fn(runner)
+}
+
+func companies(settings []autop2p.Setting) []autop2p.CompanyType {
+	var ret []autop2p.CompanyType
+	seen := make(map[autop2p.CompanyType]struct{})
+	for _, s := range settings {
+		if _, ok := seen[s.Company]; !ok {
+			seen[s.Company] = struct{}{}
+			ret = append(ret, s.Company)
+		}
+	}
+	return ret
+}
+
+func accounts(settings []autop2p.Setting) []autop2p.Setting {
+	var ret []autop2p.Setting
+	seen := make(map[string]struct{})
+	for _, s := range settings {
+		key := fmt.Sprintf("%s#%s", s.Company, s.Username)
+		if _, ok := seen[key]; !ok {
+			seen[key] = struct{}{}
+			ret = append(ret, s)
+		}
+	}
+	return ret
+}
+
+func settingLabel(setting *autop2p.Setting) string {
+	if setting.Name != "" {
+		return setting.Name
+	}
+	return setting.Username
+}
+
+func needsDetail(settings []autop2p.Setting, company autop2p.CompanyType, product *autop2p.Product) bool {
+	for _, s := range settings {
+		if s.Company == company && s.NeedsDetail() && s.MatchListing(product) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadProductDetail(ctx context.Context, product *autop2p.Product) error {
+	switch product.Company {
+	case autop2p.Honestfund:
+		return HonestfundService.GetProductDetail(ctx, product)
+	case autop2p.Peoplefund:
+		return PeoplefundService.GetProductDetail(ctx, product)
+	default:
+		return fmt.Errorf("unsupported company type %q", product.Company)
+	}
+}
+
+func listOpenProducts(ctx context.Context, company autop2p.CompanyType) ([]autop2p.Product, error) {
+	switch company {
+	case autop2p.Honestfund:
+		return HonestfundService.ListProducts(ctx)
+	case autop2p.Peoplefund:
+		return PeoplefundService.ListProducts(ctx)
+	default:
+		return nil, fmt.Errorf("unsupported company type %q", company)
+	}
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/schedule"
+	"time"
+	_ "time/tzdata"
+)
+
+const defaultTimezone = "Asia/Seoul"
+
+func daemonCommand(ctx context.Context, out *output, args []string) error {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return err
+	}
+
+	timezone := conf.Timezone
+	if timezone == "" {
+		timezone = defaultTimezone
+	}
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		return err
+	}
+
+	scheduler := schedule.New(ctx, loc, func(name string) {
+		fmt.Fprintf(logOutput, "[daemon] %s 이전 실행이 끝나지 않아 건너뜀\n", name)
+	})
+
+	// Settings sharing a schedule run as one job with one notification.
+	var specs []string
+	bySpec := make(map[string][]autop2p.Setting)
+	for _, setting := range conf.Settings {
+		spec := setting.Schedule
+		if spec == "" {
+			spec = conf.Schedule
+		}
+		if spec == "" {
+			continue
+		}
+		if _, ok := bySpec[spec]; !ok {
+			specs = append(specs, spec)
+		}
+		bySpec[spec] = append(bySpec[spec], setting)
+	}
+	if len(specs) == 0 {
+		return errors.New("no schedule in conf.yaml")
+	}
+	for _, spec := range specs {
+		if err := addJob(scheduler, conf, spec, bySpec[spec]); err != nil {
+			return fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+	}
+	fmt.Fprintf(logOutput, "[daemon] %d개 일정 등록 (%s)\n", len(specs), loc)
+	scheduler.Run()
+	fmt.Fprintln(logOutput, "[daemon] 종료")
+	return nil
+}
+
+func addJob(scheduler *schedule.Scheduler, conf *autop2p.Conf, spec string, settings []autop2p.Setting) error {
+	jobConf := *conf
+	jobConf.Settings = settings
+
+	return scheduler.Add(spec, spec, func(ctx context.Context) {
+		fmt.Fprintf(logOutput, "[daemon] %s 실행\n", spec)
+		if _, err := autoConf(ctx, &jobConf, false); err != nil {
+			fmt.Fprintf(logOutput, "[daemon] %s 실패: %v\n", spec, err)
+		}
+	})
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/ledger"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/dynamodb"
+	"os"
+)
+
+func newLedger(ctx context.Context, conf *autop2p.LedgerConf) (ledger.Store, error) {
+	switch conf.Type {
+	case "":
+		return ledger.NopStore{}, nil
+	case "file":
+		path := conf.Path
+		if path == "" {
+			path = "ledger.jsonl"
+		}
+		return ledger.NewFileStore(path), nil
+	case "dynamodb":
+		cfg, err := config.LoadDefaultConfig(ctx, config.WithRegion(conf.Region))
+		if err != nil {
+			return nil, err
+		}
+		client := dynamodb.NewFromConfig(cfg, func(o *dynamodb.Options) {
+			if conf.Endpoint != "" {
+				o.BaseEndpoint = aws.String(conf.Endpoint)
+			}
+		})
+		return ledger.NewDynamoDBStore(client, conf.Table), nil
+	default:
+		return nil, fmt.Errorf("unsupported ledger type %q", conf.Type)
+	}
+}
+
+// ledgerWarning explains why the ledger of conf can't keep a repeated run,
+// like a Lambda retry, from investing twice, or returns "" when it can.
+func ledgerWarning(conf *autop2p.LedgerConf, onLambda bool) string {
+	switch {
+	case conf.Type == "":
+		return "ledger가 설정되지 않아 같은 실행이 반복되면 중복 투자할 수 있음"
+	case conf.Type == "file" && onLambda:
+		return "Lambda의 file ledger는 새 실행 환경에서 사라져 같은 실행이 반복되면 중복 투자할 수 있음"
+	default:
+		return ""
+	}
+}
+
+func onLambda() bool {
+	return os.Getenv("AWS_LAMBDA_FUNCTION_NAME") != ""
+}
@@ -0,0 +1,14 @@
+package main
+
+import (
+	"github.com/Joddev/autop2p"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLedgerWarning(t *testing.T) {
+	assert.NotEmpty(t, ledgerWarning(&autop2p.LedgerConf{}, false))
+	assert.NotEmpty(t, ledgerWarning(&autop2p.LedgerConf{Type: "file"}, true))
+	assert.Empty(t, ledgerWarning(&autop2p.LedgerConf{Type: "file"}, false))
+	assert.Empty(t, ledgerWarning(&autop2p.LedgerConf{Type: "dynamodb"}, true))
+}
@@ -2,87 +2,664 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/Joddev/autop2p"
 	"github.com/Joddev/autop2p/honestfund"
+	"github.com/Joddev/autop2p/ledger"
 	"github.com/Joddev/autop2p/peoplefund"
+	"github.com/Joddev/autop2p/session"
+	"github.com/Joddev/autop2p/util"
 	"github.com/aws/aws-lambda-go/lambda"
-	"gopkg.in/yaml.v3"
+	"io"
 	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
 )
 
-func Run(ctx context.Context) {
-	auto()
-	ctx.Done()
+// investTimeout bounds an investment once it has started. Neither the
+// deadline margin nor a signal cuts one short, so it must fit in the margin
+// together with the notification.
+const investTimeout = 15 * time.Second
+
+const deadlineMargin = investTimeout + notifyTimeout
+
+var confPath = "conf.yaml"
+
+var logOutput io.Writer = os.Stdout
+
+type Event struct {
+	DryRun bool `json:"dryRun"`
+}
+
+type Report struct {
+	Investments []Investment
+	// Unconfirmed investments got a response that disagrees with the holdings,
+	// so they need checking by hand.
+	Unconfirmed []Investment `json:",omitempty"`
+	Failures    []Failure
+	Rejections  []Rejection `json:",omitempty"`
+}
+
+type Investment struct {
+	Company  autop2p.CompanyType
+	Username string
+	Setting  string
+	Product  autop2p.Product
+	Amount   int
+	// Requested is set when a partial fill invested less than the setting asked for.
+	Requested int `json:",omitempty"`
+	// Reason is why an unconfirmed investment could not be confirmed.
+	Reason string `json:",omitempty"`
+}
+
+// Rejection explains why a setting passed over a product. Reports carry them
+// only for dry runs.
+type Rejection struct {
+	Company  autop2p.CompanyType
+	Username string
+	Setting  string
+	Product  autop2p.Product
+	Reasons  []autop2p.Reason
+}
+
+type Failure struct {
+	Company  autop2p.CompanyType
+	Username string
+	Setting  string
+	Error    string
+}
+
+func Run(ctx context.Context, event Event) (*Report, error) {
+	ctx, cancel := withDeadlineMargin(ctx)
+	defer cancel()
+
+	return auto(ctx, event.DryRun)
+}
+
+// withDeadlineMargin stops the run from starting new investments early enough
+// to finish the one in flight and notify before Lambda kills it. The margin is
+// at most a fifth of the time left, so a short timeout still leaves most of it
+// for investing.
+func withDeadlineMargin(ctx context.Context) (context.Context, context.CancelFunc) {
+	if deadline, ok := ctx.Deadline(); ok {
+		margin := min(deadlineMargin, max(time.Until(deadline)/5, 0))
+		return context.WithDeadline(ctx, deadline.Add(-margin))
+	}
+	return context.WithCancel(ctx)
+}
+
+func auto(ctx context.Context, dryRun bool) (*Report, error) {
+	conf, err := loadConf(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return autoConf(ctx, conf, dryRun)
+}
+
+func autoConf(ctx context.Context, conf *autop2p.Conf, dryRun bool) (*Report, error) {
+	notifier, err := newNotifier(conf.Notifiers)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := execute(ctx, conf, dryRun)
+
+	notifyCtx, cancel := context.WithTimeout(context.Background(), notifyTimeout)
+	defer cancel()
+	if err := notifier.Notify(notifyCtx, newMessage(conf.Settings, report, err, dryRun)); err != nil {
+		fmt.Fprintf(logOutput, "알림 실패: %v\n", err)
+	}
+
+	return report, err
+}
+
+func execute(ctx context.Context, conf *autop2p.Conf, dryRun bool) (*Report, error) {
+	store, err := newLedger(ctx, &conf.Ledger)
+	if err != nil {
+		return nil, err
+	}
+	if warning := ledgerWarning(&conf.Ledger, onLambda()); warning != "" && !dryRun {
+		fmt.Fprintf(logOutput, "경고: %s\n", warning)
+	}
+
+	cache, err := newSessionCache(&conf.Session)
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := autop2p.NewCatalog(listOpenProducts)
+	catalog.Prefetch(ctx, companies(conf.Settings)...)
+
+	// The settings of an account run one after another, so the map only needs
+	// guarding against other accounts.
+	var mu sync.Mutex
+	sessions := make(map[accountKey]*session.Session)
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		key := accountOf(setting)
+		mu.Lock()
+		s, ok := sessions[key]
+		mu.Unlock()
+		if !ok {
+			var err error
+			if s, err = openSession(ctx, setting, cache); err != nil {
+				return nil, err
+			}
+			mu.Lock()
+			sessions[key] = s
+			mu.Unlock()
+		}
+		return newRunner(setting, catalog, s)
+	}
+	workers := conf.Concurrency
+	if workers == 0 {
+		workers = defaultConcurrency
+	}
+	return run(ctx, conf.Settings, store, open, workers, dryRun), nil
+}
+
+type openRunner func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error)
+
+// stopError ends a setting early; action says how much of the rest of the run
+// it takes down with it.
+type stopError struct {
+	action autop2p.Action
+	err    error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stopError) Unwrap() error {
+	return e.err
+}
+
+// expectedStop reports whether err is a setting running out of balance and
+// stopping as the default policy says, which is how a run normally ends
+// rather than a failure.
+func expectedStop(err error) bool {
+	var stop *stopError
+	var investErr *autop2p.InvestError
+	return errors.As(err, &stop) && stop.action == autop2p.StopSetting &&
+		errors.As(err, &investErr) && investErr.Code == autop2p.InsufficientBalance
+}
+
+// settingResult is what one setting produced. Results are merged in setting
+// order once every setting is done, so the report and log never depend on
+// which worker finished first.
+type settingResult struct {
+	investments []Investment
+	rejections  []autop2p.Rejection
+	err         error
+	skipped     bool
+}
+
+// halt records the stops that keep later settings from starting.
+type halt struct {
+	mu        sync.Mutex
+	aborted   error
+	companies map[autop2p.CompanyType]error
+}
+
+func (h *halt) check(ctx context.Context, company autop2p.CompanyType) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if h.aborted != nil {
+		return fmt.Errorf("run aborted: %w", h.aborted)
+	}
+	if err, ok := h.companies[company]; ok {
+		return fmt.Errorf("%s stopped: %w", company, err)
+	}
+	return nil
+}
+
+func (h *halt) stop(company autop2p.CompanyType, err error) {
+	var stop *stopError
+	if !errors.As(err, &stop) {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	switch stop.action {
+	case autop2p.StopCompany:
+		if _, ok := h.companies[company]; !ok {
+			h.companies[company] = stop.err
+		}
+	case autop2p.AbortRun:
+		if h.aborted == nil {
+			h.aborted = stop.err
+		}
+	}
+}
+
+type accountKey struct {
+	company  autop2p.CompanyType
+	username string
+}
+
+func accountOf(setting *autop2p.Setting) accountKey {
+	return accountKey{setting.Company, setting.Username}
+}
+
+// account is what the settings of one account share. They run one after
+// another, so each starts from the holdings and investments the previous ones
+// left behind.
+type account struct {
+	limits *autop2p.LimitChecker
+	// placed holds the products invested in, or planned, by earlier settings.
+	placed map[string]struct{}
+	// balance is what a dry run has left to plan with. It is read once, when
+	// hasBalance is still false.
+	balance    int
+	hasBalance bool
+}
+
+// groupByAccount returns the setting indexes of each account, in the order the
+// accounts first appear.
+func groupByAccount(settings []autop2p.Setting) [][]int {
+	var groups [][]int
+	index := make(map[accountKey]int)
+	for i := range settings {
+		key := accountOf(&settings[i])
+		g, ok := index[key]
+		if !ok {
+			g = len(groups)
+			index[key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+	return groups
+}
+
+// run executes accounts on up to workers goroutines, the settings of each
+// account in order. A stop only affects the settings that have not started
+// yet.
+func run(ctx context.Context, settings []autop2p.Setting, store ledger.Store, open openRunner, workers int, dryRun bool) *Report {
+	results := make([]settingResult, len(settings))
+	h := &halt{companies: map[autop2p.CompanyType]error{}}
+
+	groups := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < max(workers, 1); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range groups {
+				acct := &account{placed: make(map[string]struct{})}
+				for _, i := range group {
+					setting := &settings[i]
+					if err := h.check(ctx, setting.Company); err != nil {
+						results[i] = settingResult{err: err, skipped: true}
+						continue
+					}
+
+					investments, rejections, err := runSetting(ctx, setting, acct, store, open, dryRun)
+					results[i] = settingResult{investments: investments, rejections: rejections, err: err}
+					h.stop(setting.Company, err)
+				}
+			}
+		}()
+	}
+	for _, group := range groupByAccount(settings) {
+		groups <- group
+	}
+	close(groups)
+	wg.Wait()
+
+	report := &Report{}
+	for i := range settings {
+		setting, result := &settings[i], &results[i]
+		if result.skipped {
+			fmt.Fprintf(logOutput, "%s %s 건너뜀: %v\n", setting.Company, setting.Username, result.err)
+			report.Failures = append(report.Failures, Failure{
+				Company:  setting.Company,
+				Username: setting.Username,
+				Setting:  settingLabel(setting),
+				Error:    result.err.Error(),
+			})
+			continue
+		}
+
+		for _, i := range result.investments {
+			if i.Reason != "" {
+				report.Unconfirmed = append(report.Unconfirmed, i)
+			} else {
+				report.Investments = append(report.Investments, i)
+			}
+		}
+		if dryRun {
+			for _, r := range result.rejections {
+				report.Rejections = append(report.Rejections, Rejection{
+					Company:  setting.Company,
+					Username: setting.Username,
+					Setting:  settingLabel(setting),
+					Product:  r.Product,
+					Reasons:  r.Reasons,
+				})
+			}
+		}
+
+		count, amount := 0, 0
+		for _, i := range result.investments {
+			if i.Reason != "" {
+				fmt.Fprintf(logOutput, "%s %s %s %d원 확인 필요: %s\n",
+					setting.Company, setting.Username, i.Product.Title, i.Amount, i.Reason)
+				continue
+			}
+			count++
+			amount += i.Amount
+		}
+		if dryRun {
+			fmt.Fprintf(logOutput, "[plan] %s %s %d건 총 투자 예정 금액 %d원\n",
+				setting.Company, setting.Username, count, amount)
+		} else {
+			fmt.Fprintf(logOutput, "%s %s %d건 총 투자 금액 %d원\n",
+				setting.Company, setting.Username, count, amount)
+		}
+
+		if expectedStop(result.err) {
+			fmt.Fprintf(logOutput, "%s %s 예치금 부족으로 중단\n", setting.Company, setting.Username)
+		} else if result.err != nil {
+			fmt.Fprintf(logOutput, "%s %s 실패: %v\n", setting.Company, setting.Username, result.err)
+			report.Failures = append(report.Failures, Failure{
+				Company:  setting.Company,
+				Username: setting.Username,
+				Setting:  settingLabel(setting),
+				Error:    result.err.Error(),
+			})
+		}
+	}
+	return report
 }
 
-func auto() {
-	for _, setting := range loadSettings() {
-		runner := newRunner(&setting)
-		products := runner.ListProducts()
+func runSetting(ctx context.Context, setting *autop2p.Setting, acct *account, store ledger.Store, open openRunner, dryRun bool) ([]Investment, []autop2p.Rejection, error) {
+	runner, err := open(ctx, setting)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	products, rejections, err := runner.ListProducts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries, err := store.List(ctx, setting.Company, setting.Username)
+	if err != nil {
+		return nil, rejections, err
+	}
+	investedProductIds := ledger.InvestedProductIds(entries)
+
+	if acct.limits == nil {
+		if acct.limits, err = newLimitChecker(ctx, runner, setting); err != nil {
+			return nil, rejections, err
+		}
+	}
 
-		candidates := filter(products, setting)
+	candidates, filtered, err := filter(ctx, products, setting, runner.LoadDetail)
+	rejections = append(rejections, filtered...)
+	if err != nil {
+		return nil, rejections, err
+	}
+	setting.Rank(candidates)
 
-		count := 0
-		for _, p := range candidates {
-			err := runner.InvestProduct(&p, setting.Amount)
+	reject := func(p autop2p.Product, code string, detail string) {
+		rejections = append(rejections, autop2p.Rejection{
+			Product: p,
+			Reasons: []autop2p.Reason{{Code: code, Detail: detail}},
+		})
+	}
+
+	var investments []Investment
+	for _, p := range candidates {
+		if err := ctx.Err(); err != nil {
+			return investments, rejections, err
+		}
+
+		if _, ok := investedProductIds[p.Id]; ok {
+			reject(p, autop2p.AlreadyInvested, "recorded in ledger")
+			continue
+		}
+		if _, ok := acct.placed[p.Id]; ok {
+			reject(p, autop2p.AlreadyInvested, "placed by an earlier setting of the account")
+			continue
+		}
+
+		requested := setting.AmountFor(&p)
+		amount, err := place(ctx, runner, store, acct, setting, &p, setting.FillAmount(&p), dryRun)
+		var investErr *autop2p.InvestError
+		if err != nil && !errors.As(err, &investErr) {
+			return investments, rejections, err
+		}
+		if err != nil && investErr.Code != autop2p.Unconfirmed {
+			reject(p, autop2p.RejectedByPreCheck, investErr.Error())
+		} else {
+			// Unconfirmed money may be committed, so it counts towards the limits.
+			acct.limits.Add(&p, amount)
+			acct.placed[p.Id] = struct{}{}
+			investment := Investment{
+				Company:  setting.Company,
+				Username: setting.Username,
+				Setting:  settingLabel(setting),
+				Product:  p,
+				Amount:   amount,
+			}
+			if amount != requested {
+				investment.Requested = requested
+			}
 			if err != nil {
-				switch err.Code {
-				case autop2p.Duplicated:
-				case autop2p.InsufficientCapacity:
-					continue
-				case autop2p.InsufficientBalance:
-					break
-				default:
-					panic(err)
+				investment.Reason = investErr.Message
+			}
+			investments = append(investments, investment)
+		}
+
+		if err != nil {
+			if action := setting.ActionFor(err); action != autop2p.Continue {
+				return investments, rejections, &stopError{action: action, err: err}
+			}
+		}
+	}
+	return investments, rejections, nil
+}
+
+// place checks or invests amount in product, retrying with the reduced amount
+// the setting allows for partial fills. It returns the amount last tried.
+// A dry run plans against the account's balance, since nothing is withdrawn.
+func place(ctx context.Context, runner autop2p.Runner, store ledger.Store, acct *account, setting *autop2p.Setting, product *autop2p.Product, amount int, dryRun bool) (int, error) {
+	if dryRun && !acct.hasBalance {
+		balance, err := runner.Balance(ctx)
+		if err != nil {
+			return amount, err
+		}
+		acct.balance, acct.hasBalance = balance, true
+	}
+
+	for {
+		err := acct.limits.Check(product, amount)
+		if err == nil {
+			if dryRun {
+				err = runner.CheckProduct(ctx, product, amount)
+				if err == nil && amount > acct.balance {
+					err = &autop2p.InvestError{Code: autop2p.InsufficientBalance, Available: acct.balance}
 				}
 			} else {
-				count += 1
+				err = invest(ctx, runner, store, setting, product, amount)
 			}
 		}
-		fmt.Printf("%s %s %d건 총 투자 금액 %d원\n",
-			setting.Company, setting.Username, count, setting.Amount*count)
+
+		reduced, ok := setting.PartialAmount(amount, err)
+		if !ok {
+			if dryRun && err == nil {
+				acct.balance -= amount
+			}
+			return amount, err
+		}
+		amount = reduced
+	}
+}
+
+func newLimitChecker(ctx context.Context, runner autop2p.Runner, setting *autop2p.Setting) (*autop2p.LimitChecker, error) {
+	if setting.InvestorType.Limit() == nil {
+		return autop2p.NewLimitChecker(setting.InvestorType, nil), nil
+	}
+
+	holdings, err := runner.ListHoldings(ctx)
+	if err != nil {
+		return nil, err
 	}
+	return autop2p.NewLimitChecker(setting.InvestorType, holdings), nil
 }
 
-func filter(products []autop2p.Product, setting autop2p.Setting) []autop2p.Product {
+// invest starts only while ctx is live, then runs detached from it, so the
+// request, its confirmation and the ledger entries are never cut off halfway.
+func invest(ctx context.Context, runner autop2p.Runner, store ledger.Store, setting *autop2p.Setting, product *autop2p.Product, amount int) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), investTimeout)
+	defer cancel()
+
+	if err := store.Record(ctx, ledger.NewEntry(setting, product, amount, ledger.Attempted, "")); err != nil {
+		return err
+	}
+
+	investErr := runner.InvestProduct(ctx, product, amount)
+
+	// Only a refusal by the platform is known to have failed. Any other error
+	// may have come after the money was committed.
+	entry := ledger.NewEntry(setting, product, amount, ledger.Invested, "")
+	if investErr != nil {
+		entry.Result = ledger.Unconfirmed
+		entry.Code = errorCode(investErr)
+		var refusal *autop2p.InvestError
+		if errors.As(investErr, &refusal) && refusal.Code != autop2p.Unconfirmed {
+			entry.Result = ledger.Failed
+		}
+	}
+	if err := store.Record(ctx, entry); err != nil {
+		return err
+	}
+
+	return investErr
+}
+
+func errorCode(err error) string {
+	var investErr *autop2p.InvestError
+	if errors.As(err, &investErr) {
+		return investErr.Code
+	}
+	return err.Error()
+}
+
+// filter loads product details only for products that already pass the listing
+// checks, since each detail is a separate request.
+func filter(ctx context.Context, products []autop2p.Product, setting *autop2p.Setting, loadDetail func(ctx context.Context, product *autop2p.Product) error) ([]autop2p.Product, []autop2p.Rejection, error) {
 	var ret []autop2p.Product
+	var rejections []autop2p.Rejection
 	for _, p := range products {
-		if setting.Match(&p) {
-			ret = append(ret, p)
+		// Details are never loaded for products the listing already rules out,
+		// so their detail fields would only add noise to the reasons.
+		reasons := setting.ExplainListing(&p)
+		if len(reasons) == 0 {
+			if setting.NeedsDetail() {
+				if err := loadDetail(ctx, &p); err != nil {
+					if !productError(ctx, err) {
+						return nil, rejections, err
+					}
+					rejections = append(rejections, autop2p.Rejection{
+						Product: p,
+						Reasons: []autop2p.Reason{{Code: autop2p.DetailUnavailable, Detail: err.Error()}},
+					})
+					continue
+				}
+			}
+			reasons = setting.Explain(&p)
+		}
+		if len(reasons) > 0 {
+			rejections = append(rejections, autop2p.Rejection{Product: p, Reasons: reasons})
+			continue
 		}
+		ret = append(ret, p)
+	}
+	return ret, rejections, nil
+}
+
+// productError reports whether err is about one product only, like a detail
+// that closed since the listing or no longer parses, rather than the run
+// ending or the session breaking.
+func productError(ctx context.Context, err error) bool {
+	var expired *util.SessionExpiredError
+	var authErr *autop2p.AuthError
+	return ctx.Err() == nil && !errors.As(err, &expired) && !errors.As(err, &authErr)
+}
+
+func openSession(ctx context.Context, setting *autop2p.Setting, cache session.Cache) (*session.Session, error) {
+	switch setting.Company {
+	case autop2p.Honestfund:
+		return honestfund.OpenSession(ctx, setting, HonestfundService, cache)
+	case autop2p.Peoplefund:
+		return peoplefund.OpenSession(ctx, setting, PeoplefundService, cache)
+	default:
+		return nil, fmt.Errorf("unsupported company type %q", setting.Company)
 	}
-	return ret
 }
 
-func newRunner(setting *autop2p.Setting) autop2p.Runner {
+func newRunner(setting *autop2p.Setting, catalog *autop2p.Catalog, s *session.Session) (autop2p.Runner, error) {
 	switch setting.Company {
 	case autop2p.Honestfund:
-		return honestfund.NewRunner(setting, HonestfundService)
+		return honestfund.NewRunner(setting, HonestfundService, catalog, s), nil
 	case autop2p.Peoplefund:
-		return peoplefund.NewRunner(setting, PeoplefundService)
+		return peoplefund.NewRunner(setting, PeoplefundService, catalog, s), nil
 	default:
-		panic("unsupported company type")
+		return nil, fmt.Errorf("unsupported company type %q", setting.Company)
 	}
 }
 
-func loadSettings() []autop2p.Setting {
-	yamlFile, err := ioutil.ReadFile("conf.yaml")
+func loadConf(ctx context.Context) (*autop2p.Conf, error) {
+	yamlFile, err := ioutil.ReadFile(confPath)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	conf := &autop2p.Conf{}
-	err = yaml.Unmarshal(yamlFile, conf)
+	conf, err := autop2p.LoadConf(yamlFile)
 	if err != nil {
-		panic(err)
+		return nil, confError(err)
 	}
 
-	return conf.Settings
+	if err := resolveSecrets(ctx, conf); err != nil {
+		return nil, fmt.Errorf("%s: %w", confPath, err)
+	}
+
+	configureServices(conf)
+
+	return conf, nil
+}
+
+func confError(err error) error {
+	var errs autop2p.ValidationErrors
+	if !errors.As(err, &errs) {
+		return fmt.Errorf("%s: %w", confPath, err)
+	}
+
+	lines := make([]string, len(errs))
+	for i, e := range errs {
+		lines[i] = fmt.Sprintf("%s:%v", confPath, e)
+	}
+	return errors.New(strings.Join(lines, "\n"))
 }
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCommand(os.Args[1:]))
+	}
 	lambda.Start(Run)
 }
-
-
@@ -0,0 +1,415 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/ledger"
+	"github.com/Joddev/autop2p/util"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeRunner lists products and fails checks for the product ids in errs.
+type fakeRunner struct {
+	products []autop2p.Product
+	errs     map[string]error
+	checked  []string
+	holdings int
+	balance  int
+	// detailErrs fails LoadDetail for the product ids in it.
+	detailErrs map[string]error
+}
+
+func (r *fakeRunner) ListProducts(ctx context.Context) ([]autop2p.Product, []autop2p.Rejection, error) {
+	return r.products, nil, nil
+}
+
+func (r *fakeRunner) LoadDetail(ctx context.Context, product *autop2p.Product) error {
+	return r.detailErrs[product.Id]
+}
+
+func (r *fakeRunner) CheckProduct(ctx context.Context, product *autop2p.Product, amount int) error {
+	r.checked = append(r.checked, product.Id)
+	return r.errs[product.Id]
+}
+
+func (r *fakeRunner) InvestProduct(ctx context.Context, product *autop2p.Product, amount int) error {
+	return r.CheckProduct(ctx, product, amount)
+}
+
+func (r *fakeRunner) ListHoldings(ctx context.Context) ([]autop2p.Holding, error) {
+	r.holdings++
+	return nil, nil
+}
+
+func (r *fakeRunner) Balance(ctx context.Context) (int, error) {
+	return r.balance, nil
+}
+
+func newFakeRunner(errs map[string]error) *fakeRunner {
+	return &fakeRunner{
+		products: []autop2p.Product{
+			{Id: "1", Title: "1", Category: autop2p.PF, Rate: 10, Period: 6, RemainAmount: 1000000},
+			{Id: "2", Title: "2", Category: autop2p.PF, Rate: 10, Period: 6, RemainAmount: 1000000},
+			{Id: "3", Title: "3", Category: autop2p.PF, Rate: 10, Period: 6, RemainAmount: 1000000},
+		},
+		errs:    errs,
+		balance: 100000000,
+	}
+}
+
+func newTestSetting(company autop2p.CompanyType, username string, policy autop2p.Policy) autop2p.Setting {
+	return autop2p.Setting{
+		Username:   username,
+		Company:    company,
+		Amount:     10000,
+		PeriodMax:  12,
+		RateMax:    20,
+		Categories: []autop2p.Category{autop2p.PF},
+		Policy:     policy,
+	}
+}
+
+func TestRun_Policy(t *testing.T) {
+	logOutput = io.Discard
+
+	balance := &autop2p.InvestError{Code: autop2p.InsufficientBalance}
+	duplicated := &autop2p.InvestError{Code: autop2p.Duplicated}
+	capacity := &autop2p.InvestError{Code: autop2p.InsufficientCapacity}
+
+	tests := []struct {
+		name    string
+		policy  autop2p.Policy
+		errs    map[string]error
+		checked map[string][]string
+		failed  []string
+	}{
+		{
+			name:    "duplicated continues by default",
+			errs:    map[string]error{"1": duplicated},
+			checked: map[string][]string{"a": {"1", "2", "3"}, "b": {"1", "2", "3"}, "c": {"1", "2", "3"}},
+		},
+		{
+			name:    "insufficient capacity continues by default",
+			errs:    map[string]error{"2": capacity},
+			checked: map[string][]string{"a": {"1", "2", "3"}, "b": {"1", "2", "3"}, "c": {"1", "2", "3"}},
+		},
+		{
+			name:    "insufficient balance stops the setting by default",
+			errs:    map[string]error{"1": balance},
+			checked: map[string][]string{"a": {"1"}, "b": {"1"}, "c": {"1"}},
+		},
+		{
+			name:    "configured continue",
+			policy:  autop2p.Policy{autop2p.InsufficientBalance: autop2p.Continue},
+			errs:    map[string]error{"1": balance},
+			checked: map[string][]string{"a": {"1", "2", "3"}, "b": {"1", "2", "3"}, "c": {"1", "2", "3"}},
+		},
+		{
+			name:    "stop company skips the company's other settings",
+			policy:  autop2p.Policy{autop2p.Duplicated: autop2p.StopCompany},
+			errs:    map[string]error{"2": duplicated},
+			checked: map[string][]string{"a": {"1", "2"}, "c": {"1", "2"}},
+			failed:  []string{"a", "b", "c"},
+		},
+		{
+			name:    "abort run skips every remaining setting",
+			policy:  autop2p.Policy{autop2p.Duplicated: autop2p.AbortRun},
+			errs:    map[string]error{"3": duplicated},
+			checked: map[string][]string{"a": {"1", "2", "3"}},
+			failed:  []string{"a", "b", "c"},
+		},
+		{
+			name:    "other errors stop the setting",
+			errs:    map[string]error{"2": errors.New("network down")},
+			checked: map[string][]string{"a": {"1", "2"}, "b": {"1", "2"}, "c": {"1", "2"}},
+			failed:  []string{"a", "b", "c"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			settings := []autop2p.Setting{
+				newTestSetting(autop2p.Honestfund, "a", tt.policy),
+				newTestSetting(autop2p.Honestfund, "b", tt.policy),
+				newTestSetting(autop2p.Peoplefund, "c", tt.policy),
+			}
+			runners := map[string]*fakeRunner{}
+			open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+				r := newFakeRunner(tt.errs)
+				runners[setting.Username] = r
+				return r, nil
+			}
+
+			report := run(context.Background(), settings, ledger.NopStore{}, open, 1, true)
+
+			checked := map[string][]string{}
+			for username, r := range runners {
+				checked[username] = r.checked
+			}
+			assert.Equal(t, tt.checked, checked)
+
+			var failed []string
+			for _, f := range report.Failures {
+				failed = append(failed, f.Username)
+			}
+			assert.Equal(t, tt.failed, failed)
+		})
+	}
+}
+
+func TestRun_PolicyFailures(t *testing.T) {
+	logOutput = io.Discard
+
+	settings := []autop2p.Setting{
+		newTestSetting(autop2p.Honestfund, "a", autop2p.Policy{autop2p.InsufficientBalance: autop2p.StopCompany}),
+		newTestSetting(autop2p.Honestfund, "b", nil),
+	}
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		return newFakeRunner(map[string]error{"2": &autop2p.InvestError{Code: autop2p.InsufficientBalance}}), nil
+	}
+
+	report := run(context.Background(), settings, ledger.NopStore{}, open, 1, true)
+
+	assert.Len(t, report.Investments, 1)
+	assert.Equal(t, []Failure{
+		{Company: autop2p.Honestfund, Username: "a", Setting: "a", Error: "Insufficient balance"},
+		{Company: autop2p.Honestfund, Username: "b", Setting: "b", Error: "Honestfund stopped: Insufficient balance"},
+	}, report.Failures)
+}
+
+func TestRun_Concurrent(t *testing.T) {
+	logOutput = io.Discard
+
+	var settings []autop2p.Setting
+	for i := 0; i < 20; i++ {
+		settings = append(settings, newTestSetting(autop2p.Honestfund, fmt.Sprintf("user%02d", i), nil))
+	}
+
+	var mu sync.Mutex
+	opened := 0
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened++
+		if setting.Username == "user07" {
+			return nil, errors.New("login failed")
+		}
+		return newFakeRunner(nil), nil
+	}
+
+	report := run(context.Background(), settings, ledger.NopStore{}, open, 4, false)
+
+	assert.Equal(t, 20, opened)
+	assert.Len(t, report.Investments, 19*3)
+	for i, investment := range report.Investments {
+		setting := i / 3
+		if setting >= 7 {
+			setting++
+		}
+		assert.Equal(t, fmt.Sprintf("user%02d", setting), investment.Username, "investments keep setting order")
+	}
+	assert.Equal(t, []Failure{{Company: autop2p.Honestfund, Username: "user07", Setting: "user07", Error: "login failed"}}, report.Failures)
+}
+
+func TestRun_Unconfirmed(t *testing.T) {
+	logOutput = io.Discard
+
+	settings := []autop2p.Setting{newTestSetting(autop2p.Honestfund, "a", nil)}
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		return newFakeRunner(map[string]error{
+			"2": &autop2p.InvestError{Code: autop2p.Unconfirmed, Message: "response succeeded but product is not in holdings"},
+		}), nil
+	}
+
+	report := run(context.Background(), settings, ledger.NopStore{}, open, 1, false)
+
+	assert.Len(t, report.Investments, 2)
+	assert.Len(t, report.Unconfirmed, 1)
+	assert.Equal(t, "2", report.Unconfirmed[0].Product.Id)
+	assert.Equal(t, "response succeeded but product is not in holdings", report.Unconfirmed[0].Reason)
+	assert.Empty(t, report.Failures)
+}
+
+func TestWithDeadlineMargin(t *testing.T) {
+	parent, cancel := context.WithTimeout(context.Background(), 6*time.Second)
+	defer cancel()
+	ctx, cancel := withDeadlineMargin(parent)
+	defer cancel()
+
+	deadline, _ := ctx.Deadline()
+	remaining := time.Until(deadline)
+	assert.True(t, remaining > 4*time.Second, "short timeouts keep most of their time, got %v", remaining)
+	assert.Nil(t, ctx.Err())
+
+	parent, cancel = context.WithTimeout(context.Background(), 120*time.Second)
+	defer cancel()
+	ctx, cancel = withDeadlineMargin(parent)
+	defer cancel()
+
+	deadline, _ = ctx.Deadline()
+	parentDeadline, _ := parent.Deadline()
+	assert.Equal(t, deadlineMargin, parentDeadline.Sub(deadline))
+}
+
+func TestRun_SharedAccount(t *testing.T) {
+	logOutput = io.Discard
+
+	first := newTestSetting(autop2p.Peoplefund, "a", nil)
+	first.InvestorType = autop2p.GeneralInvestor
+	first.Amount = 500000
+	second := first
+	second.Amount = 10000
+	settings := []autop2p.Setting{first, newTestSetting(autop2p.Honestfund, "b", nil), second}
+
+	var mu sync.Mutex
+	runners := map[string]*fakeRunner{}
+	opened := map[string]int{}
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		opened[setting.Username]++
+		if _, ok := runners[setting.Username]; !ok {
+			runners[setting.Username] = newFakeRunner(nil)
+		}
+		return runners[setting.Username], nil
+	}
+
+	report := run(context.Background(), settings, ledger.NopStore{}, open, 4, true)
+
+	assert.Equal(t, map[string]int{"a": 2, "b": 1}, opened)
+	assert.Equal(t, 1, runners["a"].holdings, "the account's limits are read once")
+	assert.Equal(t, []string{"1", "2", "3"}, runners["a"].checked, "the second setting never re-plans the first one's products")
+
+	var amounts []int
+	for _, i := range report.Investments {
+		if i.Username == "a" {
+			amounts = append(amounts, i.Amount)
+		}
+	}
+	assert.Equal(t, []int{500000, 500000, 500000}, amounts)
+	rejected := 0
+	for _, r := range report.Rejections {
+		if r.Username == "a" && r.Reasons[0].Code == autop2p.AlreadyInvested {
+			rejected++
+		}
+	}
+	assert.Equal(t, 3, rejected)
+}
+
+func TestInvest_Ledger(t *testing.T) {
+	tests := []struct {
+		err    error
+		result string
+	}{
+		{nil, ledger.Invested},
+		{&autop2p.InvestError{Code: autop2p.Duplicated}, ledger.Failed},
+		{&autop2p.InvestError{Code: autop2p.Unconfirmed}, ledger.Unconfirmed},
+		{context.DeadlineExceeded, ledger.Unconfirmed},
+	}
+	for _, tt := range tests {
+		store := ledger.NewFileStore(t.TempDir() + "/ledger.jsonl")
+		setting := newTestSetting(autop2p.Honestfund, "a", nil)
+		runner := newFakeRunner(map[string]error{"1": tt.err})
+
+		err := invest(context.Background(), runner, store, &setting, &runner.products[0], 10000)
+		assert.Equal(t, tt.err, err)
+
+		entries, _ := store.List(context.Background(), autop2p.Honestfund, "a")
+		assert.Len(t, entries, 2)
+		assert.Equal(t, tt.result, entries[1].Result)
+	}
+}
+
+func TestRun_DryRunBalance(t *testing.T) {
+	logOutput = io.Discard
+
+	first := newTestSetting(autop2p.Honestfund, "a", nil)
+	second := newTestSetting(autop2p.Honestfund, "a", nil)
+	second.PartialFill = true
+	settings := []autop2p.Setting{first, second}
+
+	runner := newFakeRunner(nil)
+	runner.balance = 25000
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		return runner, nil
+	}
+
+	report := run(context.Background(), settings, ledger.NopStore{}, open, 1, true)
+
+	var amounts []int
+	for _, i := range report.Investments {
+		amounts = append(amounts, i.Amount)
+	}
+	assert.Equal(t, []int{10000, 10000}, amounts, "the balance runs out before the third product")
+	assert.Equal(t, []string{"1", "2", "3", "3"}, runner.checked, "the second setting only gets the third product")
+}
+
+// cancellingRunner cancels the run while an investment is in flight.
+type cancellingRunner struct {
+	*fakeRunner
+	cancel       context.CancelFunc
+	investCtxErr error
+}
+
+func (r *cancellingRunner) InvestProduct(ctx context.Context, product *autop2p.Product, amount int) error {
+	r.cancel()
+	r.investCtxErr = ctx.Err()
+	return r.fakeRunner.InvestProduct(ctx, product, amount)
+}
+
+func TestRun_CancelFinishesInvestment(t *testing.T) {
+	logOutput = io.Discard
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := ledger.NewFileStore(t.TempDir() + "/ledger.jsonl")
+	runner := &cancellingRunner{fakeRunner: newFakeRunner(nil), cancel: cancel}
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		return runner, nil
+	}
+
+	report := run(ctx, []autop2p.Setting{newTestSetting(autop2p.Honestfund, "a", nil)}, store, open, 1, false)
+
+	assert.Nil(t, runner.investCtxErr, "the investment in flight keeps its context")
+	assert.Equal(t, []string{"1"}, runner.checked, "no investment starts after the cancel")
+	assert.Len(t, report.Investments, 1)
+
+	entries, _ := store.List(context.Background(), autop2p.Honestfund, "a")
+	assert.Len(t, entries, 2)
+	assert.Equal(t, ledger.Invested, entries[1].Result)
+}
+
+func TestRun_DetailErrors(t *testing.T) {
+	logOutput = io.Discard
+
+	setting := newTestSetting(autop2p.Honestfund, "a", nil)
+	setting.Sort = autop2p.SortClosing
+	runner := newFakeRunner(nil)
+	runner.detailErrs = map[string]error{
+		"1": &util.StatusError{StatusCode: 404},
+		"2": &autop2p.LayoutError{Company: autop2p.Honestfund, Reason: "can't parse recruitEndDate"},
+	}
+	open := func(ctx context.Context, setting *autop2p.Setting) (autop2p.Runner, error) {
+		return runner, nil
+	}
+
+	report := run(context.Background(), []autop2p.Setting{setting}, ledger.NopStore{}, open, 1, true)
+
+	assert.Empty(t, report.Failures)
+	assert.Len(t, report.Investments, 1)
+	assert.Equal(t, "3", report.Investments[0].Product.Id)
+	assert.Len(t, report.Rejections, 2)
+	for _, r := range report.Rejections {
+		assert.Equal(t, autop2p.DetailUnavailable, r.Reasons[0].Code)
+	}
+
+	runner.detailErrs = map[string]error{"1": &util.SessionExpiredError{}}
+	report = run(context.Background(), []autop2p.Setting{setting}, ledger.NopStore{}, open, 1, true)
+	assert.Len(t, report.Failures, 1)
+}
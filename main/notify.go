@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/notify"
+	"time"
+)
+
+const notifyTimeout = 5 * time.Second
+
+func newNotifier(confs []autop2p.NotifierConf) (notify.Notifier, error) {
+	notifiers := notify.Multi{}
+	for _, conf := range confs {
+		switch conf.Type {
+		case "slack":
+			notifiers = append(notifiers, notify.NewSlackNotifier(Client, conf.WebhookUrl))
+		case "telegram":
+			notifiers = append(notifiers, notify.NewTelegramNotifier(Client, conf.BotToken, conf.ChatId))
+		case "webhook":
+			notifiers = append(notifiers, notify.NewWebhookNotifier(Client, conf.Url))
+		default:
+			return nil, fmt.Errorf("unsupported notifier type %q", conf.Type)
+		}
+	}
+	return notifiers, nil
+}
+
+func newMessage(settings []autop2p.Setting, report *Report, err error, dryRun bool) *notify.Message {
+	msg := &notify.Message{DryRun: dryRun}
+	if err != nil {
+		msg.Errors = append(msg.Errors, err.Error())
+	}
+	if report == nil {
+		return msg
+	}
+
+	// Settings with the same label are indistinguishable in the report, so
+	// they are summarised once.
+	type settingKey struct {
+		company  autop2p.CompanyType
+		username string
+		label    string
+	}
+	seen := make(map[settingKey]struct{})
+	for i := range settings {
+		setting := &settings[i]
+		key := settingKey{setting.Company, setting.Username, settingLabel(setting)}
+		if _, ok := seen[key]; ok {
+			continue
+		}
+		seen[key] = struct{}{}
+		matches := func(company autop2p.CompanyType, username string, label string) bool {
+			return settingKey{company, username, label} == key
+		}
+
+		summary := notify.Summary{
+			Company:  string(setting.Company),
+			Username: setting.Username,
+			Setting:  key.label,
+		}
+		for _, i := range report.Investments {
+			if matches(i.Company, i.Username, i.Setting) {
+				summary.Count += 1
+				summary.Total += i.Amount
+				summary.Investments = append(summary.Investments, notify.Investment{
+					Title:     i.Product.Title,
+					Rate:      i.Product.Rate,
+					Amount:    i.Amount,
+					Requested: i.Requested,
+				})
+			}
+		}
+		for _, i := range report.Unconfirmed {
+			if matches(i.Company, i.Username, i.Setting) {
+				summary.Unconfirmed = append(summary.Unconfirmed, notify.Investment{
+					Title:     i.Product.Title,
+					Rate:      i.Product.Rate,
+					Amount:    i.Amount,
+					Requested: i.Requested,
+				})
+			}
+		}
+		for _, f := range report.Failures {
+			if matches(f.Company, f.Username, f.Setting) {
+				if summary.Error != "" {
+					summary.Error += "; "
+				}
+				summary.Error += f.Error
+			}
+		}
+		msg.Summaries = append(msg.Summaries, summary)
+	}
+	return msg
+}
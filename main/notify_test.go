@@ -0,0 +1,39 @@
+package main
+
+import (
+	"github.com/Joddev/autop2p"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestNewMessage_PerSetting(t *testing.T) {
+	short := newTestSetting(autop2p.Honestfund, "a", nil)
+	short.Name = "short"
+	long := newTestSetting(autop2p.Honestfund, "a", nil)
+	long.Name = "long"
+	settings := []autop2p.Setting{short, newTestSetting(autop2p.Peoplefund, "a", nil), long}
+	report := &Report{
+		Investments: []Investment{
+			{Company: autop2p.Honestfund, Username: "a", Setting: "short", Amount: 10000},
+			{Company: autop2p.Honestfund, Username: "a", Setting: "short", Amount: 20000},
+			{Company: autop2p.Peoplefund, Username: "a", Setting: "a", Amount: 30000},
+			{Company: autop2p.Honestfund, Username: "a", Setting: "long", Amount: 40000},
+		},
+		Failures: []Failure{
+			{Company: autop2p.Honestfund, Username: "a", Setting: "long", Error: "network down"},
+		},
+	}
+
+	msg := newMessage(settings, report, nil, false)
+
+	assert.Len(t, msg.Summaries, 3)
+	assert.Equal(t, "short", msg.Summaries[0].Setting)
+	assert.Equal(t, 2, msg.Summaries[0].Count)
+	assert.Equal(t, 30000, msg.Summaries[0].Total)
+	assert.Empty(t, msg.Summaries[0].Error)
+	assert.Equal(t, 1, msg.Summaries[1].Count)
+	assert.Equal(t, "long", msg.Summaries[2].Setting)
+	assert.Equal(t, 40000, msg.Summaries[2].Total)
+	assert.Equal(t, "network down", msg.Summaries[2].Error)
+	assert.Contains(t, msg.Text(), "Honestfund a (short) 2건 총 투자 금액 30000원")
+}
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/secret"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/config"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+)
+
+func newResolver(conf *autop2p.SecretsConf) *secret.Resolver {
+	loadAwsConfig := func(ctx context.Context) (aws.Config, error) {
+		return config.LoadDefaultConfig(ctx, config.WithRegion(conf.Region))
+	}
+
+	return secret.NewResolver(map[string]secret.Backend{
+		"env": secret.EnvBackend{},
+		"ssm": secret.Lazy(func(ctx context.Context) (secret.Backend, error) {
+			cfg, err := loadAwsConfig(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return secret.NewSSMBackend(ssm.NewFromConfig(cfg, func(o *ssm.Options) {
+				if conf.SSMEndpoint != "" {
+					o.BaseEndpoint = aws.String(conf.SSMEndpoint)
+				}
+			})), nil
+		}),
+		"secretsmanager": secret.Lazy(func(ctx context.Context) (secret.Backend, error) {
+			cfg, err := loadAwsConfig(ctx)
+			if err != nil {
+				return nil, err
+			}
+			return secret.NewSecretsManagerBackend(secretsmanager.NewFromConfig(cfg, func(o *secretsmanager.Options) {
+				if conf.SecretsManagerEndpoint != "" {
+					o.BaseEndpoint = aws.String(conf.SecretsManagerEndpoint)
+				}
+			})), nil
+		}),
+		"file+age": secret.NewAgeFileBackend(conf.AgeIdentityFile),
+	})
+}
+
+func resolveSecrets(ctx context.Context, conf *autop2p.Conf) error {
+	resolver := newResolver(&conf.Secrets)
+
+	resolve := func(path string, values ...*string) error {
+		for _, v := range values {
+			resolved, err := resolver.Resolve(ctx, *v)
+			if err != nil {
+				return fmt.Errorf("%s: %w", path, err)
+			}
+			*v = resolved
+		}
+		return nil
+	}
+
+	for i := range conf.Settings {
+		s := &conf.Settings[i]
+		if err := resolve(fmt.Sprintf("settings[%d]", i), &s.Username, &s.Password); err != nil {
+			return err
+		}
+	}
+	for i := range conf.Notifiers {
+		n := &conf.Notifiers[i]
+		if err := resolve(fmt.Sprintf("notifiers[%d]", i), &n.WebhookUrl, &n.BotToken, &n.ChatId, &n.Url); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/session"
+	"os"
+	"path/filepath"
+)
+
+func newSessionCache(conf *autop2p.SessionConf) (session.Cache, error) {
+	switch conf.Cache {
+	case "none":
+		return session.NopCache{}, nil
+	case "", "file":
+		dir := conf.Dir
+		if dir == "" {
+			dir = defaultSessionDir()
+		}
+		return session.NewFileCache(dir), nil
+	default:
+		return nil, fmt.Errorf("unsupported session cache %q", conf.Cache)
+	}
+}
+
+// defaultSessionDir falls back to the temp dir where no user cache dir exists,
+// which on Lambda is the only writable location.
+func defaultSessionDir() string {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "autop2p", "sessions")
+}
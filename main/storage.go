@@ -1,15 +1,68 @@
 package main
 
 import (
+	"github.com/Joddev/autop2p"
 	"github.com/Joddev/autop2p/honestfund"
 	"github.com/Joddev/autop2p/peoplefund"
+	"github.com/Joddev/autop2p/util"
 	"net/http"
+	"strings"
+	"time"
 )
 
-var Client = &http.Client{}
+const (
+	defaultConcurrency = 4
+	defaultRatePerSec  = 5
+	defaultRateBurst   = 5
+)
+
+var RateLimiter = util.NewRateLimitTransport(http.DefaultTransport, defaultRatePerSec, defaultRateBurst)
+
+// Client retries below the breaker, so a request that fails after its retries
+// counts once towards opening the platform's circuit.
+var Client = &http.Client{
+	Transport: &util.BreakerTransport{
+		Base: &util.RetryTransport{
+			Base:       RateLimiter,
+			MaxRetries: 3,
+			BaseDelay:  200 * time.Millisecond,
+			MaxDelay:   3 * time.Second,
+		},
+		Key:       platform,
+		Threshold: 5,
+		Cooldown:  time.Minute,
+	},
+}
+
+// platform groups the hosts of a company, e.g. www and static.
+func platform(req *http.Request) string {
+	host := req.URL.Hostname()
+	switch {
+	case host == "honestfund.kr" || strings.HasSuffix(host, ".honestfund.kr"):
+		return string(autop2p.Honestfund)
+	case host == "peoplefund.co.kr" || strings.HasSuffix(host, ".peoplefund.co.kr"):
+		return string(autop2p.Peoplefund)
+	default:
+		return host
+	}
+}
 
 var HonestfundApi = honestfund.NewApi(Client)
-var HonestfundService = honestfund.NewService(HonestfundApi)
+var HonestfundService = honestfund.NewService(HonestfundApi, autop2p.PagingConf{})
 
 var PeoplefundApi = peoplefund.NewApi(Client)
-var PeoplefundService = peoplefund.NewService(PeoplefundApi)
+var PeoplefundService = peoplefund.NewService(PeoplefundApi, autop2p.PagingConf{}, nil)
+
+func configureServices(conf *autop2p.Conf) {
+	HonestfundService = honestfund.NewService(HonestfundApi, conf.Paging)
+	PeoplefundService = peoplefund.NewService(PeoplefundApi, conf.Paging, conf.PeoplefundCategories)
+
+	perSecond, burst := conf.RateLimit.PerSecond, conf.RateLimit.Burst
+	if perSecond == 0 {
+		perSecond = defaultRatePerSec
+	}
+	if burst == 0 {
+		burst = defaultRateBurst
+	}
+	RateLimiter.SetLimit(perSecond, burst)
+}
@@ -0,0 +1,84 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+type Notifier interface {
+	Notify(ctx context.Context, msg *Message) error
+}
+
+type Message struct {
+	DryRun    bool
+	Summaries []Summary
+	Errors    []string
+}
+
+type Summary struct {
+	Company  string
+	Username string
+	// Setting is the setting's name, or its username when it has none.
+	Setting     string
+	Count       int
+	Total       int
+	Investments []Investment
+	// Unconfirmed investments may or may not have gone through.
+	Unconfirmed []Investment
+	Error       string
+}
+
+type Investment struct {
+	Title  string
+	Rate   float64
+	Amount int
+	// Requested is the configured amount when Amount is a partial fill.
+	Requested int
+}
+
+func (m *Message) Text() string {
+	b := &strings.Builder{}
+	if m.DryRun {
+		b.WriteString("[autop2p] 투자 계획\n")
+	} else {
+		b.WriteString("[autop2p] 투자 결과\n")
+	}
+	for _, s := range m.Summaries {
+		name := s.Username
+		if s.Setting != "" && s.Setting != s.Username {
+			name = fmt.Sprintf("%s (%s)", s.Username, s.Setting)
+		}
+		fmt.Fprintf(b, "%s %s %d건 총 투자 금액 %d원\n", s.Company, name, s.Count, s.Total)
+		for _, i := range s.Investments {
+			fmt.Fprintf(b, "  - %s (%.2f%%) %d원", i.Title, i.Rate, i.Amount)
+			if i.Requested != 0 {
+				fmt.Fprintf(b, " (부분 투자, 요청 %d원)", i.Requested)
+			}
+			b.WriteString("\n")
+		}
+		for _, i := range s.Unconfirmed {
+			fmt.Fprintf(b, "  확인 필요: %s (%.2f%%) %d원\n", i.Title, i.Rate, i.Amount)
+		}
+		if s.Error != "" {
+			fmt.Fprintf(b, "  실패: %s\n", s.Error)
+		}
+	}
+	for _, e := range m.Errors {
+		fmt.Fprintf(b, "실패: %s\n", e)
+	}
+	return b.String()
+}
+
+type Multi []Notifier
+
+func (m Multi) Notify(ctx context.Context, msg *Message) error {
+	var errs []error
+	for _, n := range m {
+		if err := n.Notify(ctx, msg); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func newMessage() *Message {
+	return &Message{
+		Summaries: []Summary{
+			{
+				Company:  "Honestfund",
+				Username: "username",
+				Count:    2,
+				Total:    20000,
+				Investments: []Investment{
+					{Title: "SCF 플러스", Rate: 6.5, Amount: 10000},
+					{Title: "여수 마리나항만", Rate: 13, Amount: 10000, Requested: 30000},
+				},
+				Unconfirmed: []Investment{
+					{Title: "SCF 베이직 131호", Rate: 8, Amount: 10000},
+				},
+			},
+			{
+				Company:  "Peoplefund",
+				Username: "username",
+				Error:    "Peoplefund authentication failed",
+			},
+		},
+	}
+}
+
+func TestMessage_Text(t *testing.T) {
+	assert.Equal(t, `[autop2p] 투자 결과
+Honestfund username 2건 총 투자 금액 20000원
+  - SCF 플러스 (6.50%) 10000원
+  - 여수 마리나항만 (13.00%) 10000원 (부분 투자, 요청 30000원)
+  확인 필요: SCF 베이직 131호 (8.00%) 10000원
+Peoplefund username 0건 총 투자 금액 0원
+  실패: Peoplefund authentication failed
+`, newMessage().Text())
+}
+
+type notifierFunc func(ctx context.Context, msg *Message) error
+
+func (f notifierFunc) Notify(ctx context.Context, msg *Message) error {
+	return f(ctx, msg)
+}
+
+func TestMulti_Notify(t *testing.T) {
+	called := 0
+	ok := notifierFunc(func(ctx context.Context, msg *Message) error {
+		called += 1
+		return nil
+	})
+	failed := notifierFunc(func(ctx context.Context, msg *Message) error {
+		called += 1
+		return errors.New("failed")
+	})
+
+	err := Multi{failed, ok}.Notify(context.Background(), newMessage())
+
+	assert.EqualError(t, err, "failed")
+	assert.Equal(t, 2, called)
+}
@@ -0,0 +1,62 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Joddev/autop2p/util"
+	"net/http"
+	"net/url"
+)
+
+type SlackNotifier struct {
+	client     *http.Client
+	webhookUrl string
+}
+
+func NewSlackNotifier(client *http.Client, webhookUrl string) *SlackNotifier {
+	return &SlackNotifier{client: client, webhookUrl: webhookUrl}
+}
+
+type slackRequest struct {
+	Text string `json:"text"`
+}
+
+func (n *SlackNotifier) Notify(ctx context.Context, msg *Message) error {
+	return postJson(ctx, n.client, n.webhookUrl, &slackRequest{Text: msg.Text()})
+}
+
+// postJson keeps rawUrl out of its errors, since webhook urls and bot tokens
+// are secrets.
+func postJson(ctx context.Context, client *http.Client, rawUrl string, data interface{}) error {
+	body, err := util.EncodeJsonRequest(data)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", rawUrl, body)
+	if err != nil {
+		return errors.New("invalid notification url")
+	}
+	req.Header.Add("Content-Type", "application/json")
+
+	res, err := util.HandleResponse(client.Do(req))
+	if err != nil {
+		return redact(err)
+	}
+	defer res.Body.Close()
+
+	return nil
+}
+
+func redact(err error) error {
+	var statusErr *util.StatusError
+	if errors.As(err, &statusErr) {
+		return fmt.Errorf("unexpected status %d: %s", statusErr.StatusCode, statusErr.Body)
+	}
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return fmt.Errorf("%s request failed: %w", urlErr.Op, urlErr.Err)
+	}
+	return err
+}
@@ -0,0 +1,57 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSlackNotifier_Notify(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/services/T000/B000/XXXX", r.URL.Path)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	n := NewSlackNotifier(server.Client(), server.URL+"/services/T000/B000/XXXX")
+	err := n.Notify(context.Background(), newMessage())
+
+	assert.Nil(t, err)
+	assert.Equal(t, newMessage().Text(), received["text"])
+}
+
+func TestSlackNotifier_Notify_Error(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("no_service"))
+	}))
+	defer server.Close()
+
+	n := NewSlackNotifier(server.Client(), server.URL)
+	err := n.Notify(context.Background(), newMessage())
+
+	assert.Error(t, err)
+}
+
+func TestSlackNotifier_Notify_RedactsUrl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte("no_service"))
+	}))
+
+	n := NewSlackNotifier(server.Client(), server.URL+"/services/T000/B000/XXXX")
+	err := n.Notify(context.Background(), newMessage())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "XXXX")
+
+	server.Close()
+	err = n.Notify(context.Background(), newMessage())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "XXXX")
+}
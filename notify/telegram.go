@@ -0,0 +1,39 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const telegramApiUrl = "https://api.telegram.org"
+
+type TelegramNotifier struct {
+	client   *http.Client
+	apiUrl   string
+	botToken string
+	chatId   string
+}
+
+func NewTelegramNotifier(client *http.Client, botToken string, chatId string) *TelegramNotifier {
+	return &TelegramNotifier{
+		client:   client,
+		apiUrl:   telegramApiUrl,
+		botToken: botToken,
+		chatId:   chatId,
+	}
+}
+
+type telegramRequest struct {
+	ChatId string `json:"chat_id"`
+	Text   string `json:"text"`
+}
+
+func (n *TelegramNotifier) Notify(ctx context.Context, msg *Message) error {
+	return postJson(
+		ctx,
+		n.client,
+		fmt.Sprintf("%s/bot%s/sendMessage", n.apiUrl, n.botToken),
+		&telegramRequest{ChatId: n.chatId, Text: msg.Text()},
+	)
+}
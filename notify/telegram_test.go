@@ -0,0 +1,43 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTelegramNotifier_Notify(t *testing.T) {
+	var received map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/bot123:TOKEN/sendMessage", r.URL.Path)
+		_ = json.NewDecoder(r.Body).Decode(&received)
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	}))
+	defer server.Close()
+
+	n := NewTelegramNotifier(server.Client(), "123:TOKEN", "42")
+	n.apiUrl = server.URL
+	err := n.Notify(context.Background(), newMessage())
+
+	assert.Nil(t, err)
+	assert.Equal(t, "42", received["chat_id"])
+	assert.Equal(t, newMessage().Text(), received["text"])
+}
+
+func TestTelegramNotifier_Notify_RedactsToken(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"ok":false,"description":"Unauthorized"}`))
+	}))
+	defer server.Close()
+
+	n := NewTelegramNotifier(server.Client(), "123:TOKEN", "42")
+	n.apiUrl = server.URL
+	err := n.Notify(context.Background(), newMessage())
+
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "123:TOKEN")
+}
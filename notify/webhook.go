@@ -0,0 +1,24 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+)
+
+type WebhookNotifier struct {
+	client *http.Client
+	url    string
+}
+
+func NewWebhookNotifier(client *http.Client, url string) *WebhookNotifier {
+	return &WebhookNotifier{client: client, url: url}
+}
+
+type webhookRequest struct {
+	*Message
+	Text string
+}
+
+func (n *WebhookNotifier) Notify(ctx context.Context, msg *Message) error {
+	return postJson(ctx, n.client, n.url, &webhookRequest{Message: msg, Text: msg.Text()})
+}
@@ -0,0 +1,50 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestWebhookNotifier_Notify(t *testing.T) {
+	received := &struct {
+		Message
+		Text string
+	}{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(received)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	n := NewWebhookNotifier(server.Client(), server.URL)
+	err := n.Notify(context.Background(), newMessage())
+
+	assert.Nil(t, err)
+	assert.Equal(t, newMessage().Summaries, received.Summaries)
+	assert.Equal(t, newMessage().Text(), received.Text)
+}
+
+func TestWebhookNotifier_Notify_RedactsUrl(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+
+	n := NewWebhookNotifier(server.Client(), server.URL+"/hooks/SECRET")
+	err := n.Notify(context.Background(), newMessage())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "SECRET")
+
+	server.Close()
+	err = n.Notify(context.Background(), newMessage())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "SECRET")
+
+	n = NewWebhookNotifier(server.Client(), "http://[::1/hooks/SECRET")
+	err = n.Notify(context.Background(), newMessage())
+	assert.Error(t, err)
+	assert.NotContains(t, err.Error(), "SECRET")
+}
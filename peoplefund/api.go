@@ -1,8 +1,9 @@
 package peoplefund
 
 import (
-	"errors"
+	"context"
 	"fmt"
+	"github.com/Joddev/autop2p"
 	"github.com/Joddev/autop2p/util"
 	"net/http"
 	"net/url"
@@ -11,11 +12,12 @@ import (
 )
 
 type Api interface {
-	ListProducts(status string) *ListProductResponse
-	Login(email string, password string) string
-	Invest(sessionId string, uri string, loanId int, investAmount int, pointAmount int)
-	CheckInvestment(sessionId string, loanId int) *CheckInvestmentResponse
-	ListInvestedProducts(sessionId string) *ListInvestedProductsResponse
+	ListProducts(ctx context.Context, status string, page int) (*ListProductResponse, error)
+	GetProductDetail(ctx context.Context, loanId int) (*ProductDetailResponse, error)
+	Login(ctx context.Context, email string, password string) (string, error)
+	Invest(ctx context.Context, sessionId string, uri string, loanId int, investAmount int, pointAmount int) (*InvestResponse, error)
+	CheckInvestment(ctx context.Context, sessionId string, loanId int) (*CheckInvestmentResponse, error)
+	ListInvestedProducts(ctx context.Context, sessionId string) (*ListInvestedProductsResponse, error)
 }
 
 type ApiImpl struct {
@@ -26,23 +28,32 @@ func NewApi(client *http.Client) Api {
 	return &ApiImpl{client}
 }
 
-func (a *ApiImpl) ListProducts(status string) *ListProductResponse {
-	req, _ := http.NewRequest(
+func (a *ApiImpl) ListProducts(ctx context.Context, status string, page int) (*ListProductResponse, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
-		"https://static.peoplefund.co.kr/showcase/newlistGetAjax/1/",
+		fmt.Sprintf("https://static.peoplefund.co.kr/showcase/newlistGetAjax/%d/", page),
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	q := req.URL.Query()
 	q.Add("status", status)
 	req.URL.RawQuery = q.Encode()
 
-	res := util.HandleResponse(a.client.Do(req))
+	res, err := util.HandleResponse(a.client.Do(req))
+	if err != nil {
+		return nil, err
+	}
 
 	ret := &ListProductResponse{}
-	util.DecodeJsonResponse(res, ret)
+	if err := util.DecodeJsonResponse(res, ret); err != nil {
+		return nil, err
+	}
 
-	return ret
+	return ret, nil
 }
 
 type ListProductResponse struct {
@@ -62,61 +73,141 @@ type ListProductResponse struct {
 	}
 }
 
-func (a *ApiImpl) Login(email string, password string) string {
-	res := util.HandleResponse(a.client.PostForm(
+func (a *ApiImpl) GetProductDetail(ctx context.Context, loanId int) (*ProductDetailResponse, error) {
+	req, err := http.NewRequestWithContext(
+		ctx,
+		"GET",
+		fmt.Sprintf("https://static.peoplefund.co.kr/showcase/detailGetAjax/%d/", loanId),
+		nil,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := util.HandleResponse(a.client.Do(req))
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &ProductDetailResponse{}
+	if err := util.DecodeJsonResponse(res, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+type ProductDetailResponse struct {
+	Status  string
+	Message string
+	Data    struct {
+		LoanApplicationId  int     `json:"loan_application_id"`
+		Ltv                float64 `json:"ltv"`
+		CreditGrade        string  `json:"credit_grade"`
+		CollateralType     string  `json:"collateral_type"`
+		CollateralValue    int     `json:"collateral_value"`
+		BorrowerId         int     `json:"borrower_id"`
+		LoanAmount         int     `json:"loan_amount"`
+		InvestProgressRate float64 `json:"invest_progress_rate"`
+		InvestEndDatetime  string  `json:"invest_end_datetime"`
+	}
+}
+
+func (a *ApiImpl) Login(ctx context.Context, email string, password string) (string, error) {
+	httpReq, err := util.NewFormRequest(
+		ctx,
 		"https://www.peoplefund.co.kr/auth/loginAjax/",
 		url.Values{
 			"type":     {"email"},
 			"email":    {email},
 			"password": {password},
 		},
-	))
+	)
+	if err != nil {
+		return "", err
+	}
+
+	res, err := util.HandleResponse(a.client.Do(httpReq))
+	if err != nil {
+		return "", err
+	}
 	defer res.Body.Close()
 
 	for _, cookie := range res.Cookies() {
 		if cookie.Name == "SESSID" && cookie.Value != "" {
-			return cookie.Value
+			return cookie.Value, nil
 		}
 	}
-	panic(errors.New("can't find SESSID from cookies"))
+	return "", &autop2p.AuthError{
+		Company: autop2p.Peoplefund,
+		Reason:  "can't find SESSID from cookies",
+	}
 }
 
-func (a *ApiImpl) Invest(sessionId string, uri string, loanId int, investAmount int, pointAmount int) {
+func (a *ApiImpl) Invest(ctx context.Context, sessionId string, uri string, loanId int, investAmount int, pointAmount int) (*InvestResponse, error) {
 	data := url.Values{
 		"showcase_uri":        {uri},
 		"loan_application_id": {strconv.Itoa(loanId)},
 		"invest_amount":       {strconv.Itoa(investAmount)},
 		"point_amount":        {strconv.Itoa(pointAmount)},
 	}
-	httpReq, _ := http.NewRequest(
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"POST",
 		"https://www.peoplefund.co.kr/showcase/investSubmitAjax",
 		strings.NewReader(data.Encode()),
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	addSessionCookie(httpReq, sessionId)
 
 	httpReq.Header.Add("Content-Type", "application/x-www-form-urlencoded; charset=UTF-8")
 	httpReq.Header.Add("Content-Length", strconv.Itoa(len(data.Encode())))
 
-	res := util.HandleResponse(a.client.Do(httpReq))
-	defer res.Body.Close()
+	res, err := a.doWithSession(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := &InvestResponse{}
+	if err := util.DecodeJsonResponse(res, ret); err != nil {
+		return nil, err
+	}
+
+	return ret, nil
+}
+
+type InvestResponse struct {
+	Status  string
+	Message string
 }
 
-func (a *ApiImpl) CheckInvestment(sessionId string, loanId int) *CheckInvestmentResponse {
-	httpReq, _ := http.NewRequest(
+func (a *ApiImpl) CheckInvestment(ctx context.Context, sessionId string, loanId int) (*CheckInvestmentResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		fmt.Sprintf("https://www.peoplefund.co.kr/showcase/maxInvestableAmountGetAjax/%d/", loanId),
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	addSessionCookie(httpReq, sessionId)
 
-	res := util.HandleResponse(a.client.Do(httpReq))
+	res, err := a.doWithSession(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
 	ret := &CheckInvestmentResponse{}
-	util.DecodeJsonResponse(res, ret)
+	if err := util.DecodeJsonResponse(res, ret); err != nil {
+		return nil, err
+	}
 
-	return ret
+	return ret, nil
 }
 
 type CheckInvestmentResponse struct {
@@ -128,20 +219,30 @@ type CheckInvestmentResponse struct {
 	}
 }
 
-func (a *ApiImpl) ListInvestedProducts(sessionId string) *ListInvestedProductsResponse {
-	httpReq, _ := http.NewRequest(
+func (a *ApiImpl) ListInvestedProducts(ctx context.Context, sessionId string) (*ListInvestedProductsResponse, error) {
+	httpReq, err := http.NewRequestWithContext(
+		ctx,
 		"GET",
 		"https://www.peoplefund.co.kr/mypage/investlistAjax?type=showcase",
 		nil,
 	)
+	if err != nil {
+		return nil, err
+	}
 
 	addSessionCookie(httpReq, sessionId)
 
-	res := util.HandleResponse(a.client.Do(httpReq))
+	res, err := a.doWithSession(httpReq)
+	if err != nil {
+		return nil, err
+	}
+
 	ret := &ListInvestedProductsResponse{}
-	util.DecodeJsonResponse(res, ret)
+	if err := util.DecodeJsonResponse(res, ret); err != nil {
+		return nil, err
+	}
 
-	return ret
+	return ret, nil
 }
 
 type ListInvestedProductsResponse struct {
@@ -153,7 +254,9 @@ type ListInvestedProductsResponse struct {
 			Title                 string
 			LoanApplicationId     int    `json:"loan_application_id"`
 			LoanType              string `json:"loan_type"`
+			DetailedLoanType      string `json:"detailed_loan_type"`
 			LoanApplicationStatus string `json:"loan_application_status"`
+			InvestAmount          int    `json:"invest_amount"`
 		}
 	}
 }
@@ -161,3 +264,8 @@ type ListInvestedProductsResponse struct {
 func addSessionCookie(req *http.Request, sessionId string) {
 	req.AddCookie(&http.Cookie{Name: "SESSID", Value: sessionId})
 }
+
+func (a *ApiImpl) doWithSession(req *http.Request) (*http.Response, error) {
+	res, err := a.client.Do(req)
+	return util.HandleSessionResponse(res, err, "/auth/login/")
+}
@@ -0,0 +1,61 @@
+package peoplefund
+
+import (
+	"github.com/Joddev/autop2p"
+	"strings"
+)
+
+var defaultCategories = map[string]autop2p.Category{
+	"아파트담보":  autop2p.MortgageRealEstate,
+	"아파트후순위": autop2p.MortgageRealEstate,
+	"주택담보":   autop2p.MortgageRealEstate,
+	"빌라담보":   autop2p.MortgageRealEstate,
+	"오피스텔담보": autop2p.MortgageRealEstate,
+	"상가담보":   autop2p.MortgageRealEstate,
+	"토지담보":   autop2p.MortgageRealEstate,
+	"부동산담보":  autop2p.MortgageRealEstate,
+	"PF":     autop2p.PF,
+	"부동산PF":  autop2p.PF,
+	"건축자금":   autop2p.PF,
+	"브릿지":    autop2p.PF,
+	"개인신용":   autop2p.PersonalCredit,
+	"개인신용대출": autop2p.PersonalCredit,
+	"법인신용":   autop2p.CorporateCredit,
+	"기업신용":   autop2p.CorporateCredit,
+	"SCF":    autop2p.CorporateCredit,
+	"선정산":    autop2p.CorporateCredit,
+	"온라인선정산": autop2p.CorporateCredit,
+	"매출채권":   autop2p.CorporateCredit,
+	"확정매출채권": autop2p.CorporateCredit,
+	"동산담보":   autop2p.CorporateCredit,
+}
+
+type categoryTable map[string]autop2p.Category
+
+// newCategoryTable layers the configured loan types over the defaults.
+func newCategoryTable(overrides map[string]autop2p.Category) categoryTable {
+	table := make(categoryTable, len(defaultCategories)+len(overrides))
+	for loanType, category := range defaultCategories {
+		table[categoryKey(loanType)] = category
+	}
+	for loanType, category := range overrides {
+		table[categoryKey(loanType)] = category
+	}
+	return table
+}
+
+// convert prefers detailed_loan_type, which is more specific than loan_type
+// when both are mapped.
+func (t categoryTable) convert(loanType string, detailedLoanType string) autop2p.Category {
+	if c, ok := t[categoryKey(detailedLoanType)]; ok {
+		return c
+	}
+	if c, ok := t[categoryKey(loanType)]; ok {
+		return c
+	}
+	return autop2p.UNKNOWN
+}
+
+func categoryKey(loanType string) string {
+	return strings.Join(strings.Fields(strings.ToUpper(loanType)), "")
+}
@@ -0,0 +1,29 @@
+package peoplefund
+
+import (
+	"github.com/Joddev/autop2p"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCategoryTable_Convert(t *testing.T) {
+	table := newCategoryTable(nil)
+
+	assert.Equal(t, autop2p.MortgageRealEstate, table.convert("아파트담보", "아파트담보"))
+	assert.Equal(t, autop2p.MortgageRealEstate, table.convert("부동산담보", "오피스텔 담보"))
+	assert.Equal(t, autop2p.PF, table.convert("부동산", "부동산PF"))
+	assert.Equal(t, autop2p.PersonalCredit, table.convert("개인신용", ""))
+	assert.Equal(t, autop2p.CorporateCredit, table.convert("법인", "scf"))
+	assert.Equal(t, autop2p.UNKNOWN, table.convert("미술품", "미술품담보"))
+}
+
+func TestCategoryTable_Overrides(t *testing.T) {
+	table := newCategoryTable(map[string]autop2p.Category{
+		"미술품담보": autop2p.CorporateCredit,
+		"브릿지":   autop2p.MortgageRealEstate,
+	})
+
+	assert.Equal(t, autop2p.CorporateCredit, table.convert("미술품", "미술품담보"))
+	assert.Equal(t, autop2p.MortgageRealEstate, table.convert("부동산", "브릿지"))
+	assert.Equal(t, autop2p.PersonalCredit, table.convert("개인신용", ""))
+}
@@ -1,7 +1,10 @@
 package peoplefund
 
 import (
+	"context"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/session"
+	"github.com/Joddev/autop2p/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
@@ -11,61 +14,181 @@ type ServiceMock struct {
 	mock.Mock
 }
 
-func (m *ServiceMock) ListProducts() []autop2p.Product {
-	args := m.Called()
-	return args.Get(0).([]autop2p.Product)
+func (m *ServiceMock) ListProducts(ctx context.Context) ([]autop2p.Product, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]autop2p.Product), args.Error(1)
 }
 
-func (m *ServiceMock) Login(email string, password string) string {
-	args := m.Called(email, password)
-	return args.Get(0).(string)
+func (m *ServiceMock) GetProductDetail(ctx context.Context, product *autop2p.Product) error {
+	args := m.Called(ctx, product)
+	return args.Error(0)
 }
 
-func (m *ServiceMock) CheckAndInvest(sessionId string, productId string, amount int) *autop2p.InvestError {
-	args := m.Called(sessionId, productId, amount)
-	return args.Error(0).(*autop2p.InvestError)
+func (m *ServiceMock) Login(ctx context.Context, email string, password string) (string, error) {
+	args := m.Called(ctx, email, password)
+	return args.Get(0).(string), args.Error(1)
 }
 
-func (m *ServiceMock) ListInvestedProductTitles(sessionId string) map[string]struct{} {
-	args := m.Called(sessionId)
-	return args.Get(0).(map[string]struct{})
+func (m *ServiceMock) CheckInvestment(ctx context.Context, sessionId string, productId string, amount int) error {
+	args := m.Called(ctx, sessionId, productId, amount)
+	return args.Error(0)
 }
 
-func TestNewRunner(t *testing.T) {
+func (m *ServiceMock) CheckAndInvest(ctx context.Context, sessionId string, productId string, amount int) error {
+	args := m.Called(ctx, sessionId, productId, amount)
+	return args.Error(0)
+}
+
+func (m *ServiceMock) ListInvestedProductTitles(ctx context.Context, sessionId string) (map[string]struct{}, error) {
+	args := m.Called(ctx, sessionId)
+	return args.Get(0).(map[string]struct{}), args.Error(1)
+}
+
+func (m *ServiceMock) ListHoldings(ctx context.Context, sessionId string) ([]autop2p.Holding, error) {
+	args := m.Called(ctx, sessionId)
+	return args.Get(0).([]autop2p.Holding), args.Error(1)
+}
+
+func (m *ServiceMock) GetBalance(ctx context.Context, sessionId string, productId string) (int, error) {
+	args := m.Called(ctx, sessionId, productId)
+	return args.Int(0), args.Error(1)
+}
+
+func TestOpenSession(t *testing.T) {
 	m := &ServiceMock{}
-	m.On("Login", "hf@peoplefund.kr", "1234password!@#$").Return(
-		"SESSION_ID#1414",
+	m.On("Login", mock.Anything, "hf@peoplefund.kr", "1234password!@#$").Return(
+		"SESSION_ID#1414", nil,
 	)
 
-	r := NewRunner(&autop2p.Setting{
+	s, err := OpenSession(context.Background(), &autop2p.Setting{
 		Username: "hf@peoplefund.kr",
 		Password: "1234password!@#$",
-	}, m)
+	}, m, session.NopCache{})
 
-	assert.Equal(t, r.sessionId, "SESSION_ID#1414")
+	assert.Nil(t, err)
+	assert.Equal(t, s.Token(), "SESSION_ID#1414")
+}
+
+func TestRunner_InvestProduct_Relogin(t *testing.T) {
+	ctx := context.Background()
+	cache := session.NewFileCache(t.TempDir())
+	assert.Nil(t, cache.Save(ctx, autop2p.Peoplefund, "hf@peoplefund.kr", "STALE"))
+
+	m := &ServiceMock{}
+	m.On("Login", mock.Anything, "hf@peoplefund.kr", "1234password!@#$").Return("FRESH", nil)
+	m.On("CheckAndInvest", mock.Anything, "STALE", "1", 10000).Return(
+		&util.SessionExpiredError{Url: "https://example.com"},
+	)
+	m.On("CheckAndInvest", mock.Anything, "FRESH", "1", 10000).Return(nil)
+
+	setting := &autop2p.Setting{
+		Username: "hf@peoplefund.kr",
+		Password: "1234password!@#$",
+	}
+	s, err := OpenSession(ctx, setting, m, cache)
+	assert.Nil(t, err)
+	r := NewRunner(setting, m, nil, s)
+
+	err = r.InvestProduct(ctx, &autop2p.Product{Id: "1"}, 10000)
+
+	assert.Nil(t, err)
+	m.AssertNumberOfCalls(t, "Login", 1)
+	token, _ := cache.Load(ctx, autop2p.Peoplefund, "hf@peoplefund.kr")
+	assert.Equal(t, "FRESH", token)
 }
 
 func TestRunner_ListProducts(t *testing.T) {
 	m := &ServiceMock{}
-	m.On("ListInvestedProductTitles", "SESSION_ID#143").Return(
+	m.On("ListInvestedProductTitles", mock.Anything, "SESSION_ID#143").Return(
 		map[string]struct{}{
 			"TITLE#1":      {},
 			"Second Title": {},
 			"P2P":          {},
-		},
+		}, nil,
 	)
-	m.On("ListProducts").Return([]autop2p.Product{
+	m.On("ListProducts", mock.Anything).Return([]autop2p.Product{
 		{Title: "TITLE#1"},
 		{Title: "P2P-2"},
 		{Title: "Third Title"},
-	})
+	}, nil)
 
 	r := Runner{
-		sessionId: "SESSION_ID#143",
-		service:   m,
+		session: newSession(t, "SESSION_ID#143"),
+		service: m,
+		catalog: newCatalog(m),
 	}
-	p := r.ListProducts()
+	p, rejections, err := r.ListProducts(context.Background())
 
+	assert.Nil(t, err)
 	assert.Len(t, p, 2)
 	assert.Contains(t, p, autop2p.Product{Title: "Third Title"})
+	assert.Len(t, rejections, 1)
+	assert.Equal(t, autop2p.Product{Title: "TITLE#1"}, rejections[0].Product)
+	assert.Equal(t, autop2p.AlreadyInvested, rejections[0].Reasons[0].Code)
+}
+
+func TestOpenSession_AuthError(t *testing.T) {
+	m := &ServiceMock{}
+	m.On("Login", mock.Anything, "hf@peoplefund.kr", "wrong").Return(
+		"", &autop2p.AuthError{Company: autop2p.Peoplefund, Reason: "can't find SESSID from cookies"},
+	)
+
+	s, err := OpenSession(context.Background(), &autop2p.Setting{
+		Username: "hf@peoplefund.kr",
+		Password: "wrong",
+	}, m, session.NopCache{})
+
+	assert.Nil(t, s)
+	assert.IsType(t, &autop2p.AuthError{}, err)
+}
+
+func TestRunner_Balance_UsesCatalog(t *testing.T) {
+	m := &ServiceMock{}
+	m.On("ListProducts", mock.Anything).Return([]autop2p.Product{{Id: "7"}, {Id: "8"}}, nil).Once()
+	m.On("GetBalance", mock.Anything, "TOKEN", "7").Return(123000, nil)
+
+	catalog := newCatalog(m)
+	_, err := catalog.Products(context.Background(), autop2p.Peoplefund)
+	assert.Nil(t, err)
+
+	r := Runner{session: newSession(t, "TOKEN"), service: m, catalog: catalog}
+	balance, err := r.Balance(context.Background())
+
+	assert.Nil(t, err)
+	assert.Equal(t, 123000, balance)
+	m.AssertNumberOfCalls(t, "ListProducts", 1)
+}
+
+func TestRunner_ListProducts_AllowLaterRounds(t *testing.T) {
+	m := &ServiceMock{}
+	m.On("ListProducts", mock.Anything).Return([]autop2p.Product{
+		{Title: "TITLE#1"},
+		{Title: "Third Title"},
+	}, nil)
+
+	r := Runner{
+		allowLaterRounds: true,
+		service:          m,
+		catalog:          newCatalog(m),
+	}
+	p, rejections, err := r.ListProducts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, p, 2)
+	assert.Empty(t, rejections)
+	m.AssertNotCalled(t, "ListInvestedProductTitles", mock.Anything, mock.Anything)
+}
+
+func newCatalog(m *ServiceMock) *autop2p.Catalog {
+	return autop2p.NewCatalog(func(ctx context.Context, company autop2p.CompanyType) ([]autop2p.Product, error) {
+		return m.ListProducts(ctx)
+	})
+}
+
+func newSession(t *testing.T, token string) *session.Session {
+	s, err := session.Open(context.Background(), session.NopCache{}, autop2p.Peoplefund, "", func(ctx context.Context) (string, error) {
+		return token, nil
+	})
+	assert.Nil(t, err)
+	return s
 }
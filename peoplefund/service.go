@@ -1,35 +1,75 @@
 package peoplefund
 
 import (
+	"context"
 	"fmt"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 type Service interface {
-	ListProducts() []autop2p.Product
-	Login(email string, password string) string
-	CheckAndInvest(sessionId string, productId string, amount int) *autop2p.InvestError
-	ListInvestedProductTitles(sessionId string) map[string]struct{}
+	ListProducts(ctx context.Context) ([]autop2p.Product, error)
+	GetProductDetail(ctx context.Context, product *autop2p.Product) error
+	Login(ctx context.Context, email string, password string) (string, error)
+	CheckInvestment(ctx context.Context, sessionId string, productId string, amount int) error
+	CheckAndInvest(ctx context.Context, sessionId string, productId string, amount int) error
+	ListInvestedProductTitles(ctx context.Context, sessionId string) (map[string]struct{}, error)
+	ListHoldings(ctx context.Context, sessionId string) ([]autop2p.Holding, error)
+	// GetBalance reads the balance from the invest check of productId, which
+	// must be open.
+	GetBalance(ctx context.Context, sessionId string, productId string) (int, error)
 }
 
+const defaultMaxPages = 20
+
 type ServiceImpl struct {
-	api Api
+	api        Api
+	paging     autop2p.PagingConf
+	categories categoryTable
 }
 
-func NewService(api Api) Service {
-	return &ServiceImpl{api}
+// NewService ignores paging.PageSize, the showcase list has a fixed page size.
+func NewService(api Api, paging autop2p.PagingConf, categories map[string]autop2p.Category) Service {
+	if paging.MaxPages == 0 {
+		paging.MaxPages = defaultMaxPages
+	}
+	return &ServiceImpl{api, paging, newCategoryTable(categories)}
 }
 
-func (s *ServiceImpl) ListProducts() []autop2p.Product {
-	resp := s.api.ListProducts("투자모집중")
+// ListProducts walks the pages until one comes back empty or adds nothing new.
+// Products shift between pages while walking, so they are deduplicated by id.
+func (s *ServiceImpl) ListProducts(ctx context.Context) ([]autop2p.Product, error) {
+	var products []autop2p.Product
+	seen := make(map[string]struct{})
 
-	return convertToProducts(resp)
+	for page := 1; page <= s.paging.MaxPages; page++ {
+		resp, err := s.api.ListProducts(ctx, "투자모집중", page)
+		if err != nil {
+			return nil, err
+		}
+
+		added := 0
+		for _, p := range s.convertToProducts(resp) {
+			if _, ok := seen[p.Id]; ok {
+				continue
+			}
+			seen[p.Id] = struct{}{}
+			products = append(products, p)
+			added++
+		}
+
+		if added == 0 {
+			break
+		}
+	}
+	return products, nil
 }
 
-func convertToProducts(res *ListProductResponse) []autop2p.Product {
+func (s *ServiceImpl) convertToProducts(res *ListProductResponse) []autop2p.Product {
 	products := make([]autop2p.Product, len(res.Data.List))
 	for i, p := range res.Data.List {
 		products[i] = autop2p.Product{
@@ -39,63 +79,194 @@ func convertToProducts(res *ListProductResponse) []autop2p.Product {
 			Period:       p.LoanApplicationTerm,
 			Company:      autop2p.Peoplefund,
 			RemainAmount: p.RemainAmount,
-			Category:     convertCategory(p.LoanType),
+			Category:     s.categories.convert(p.LoanType, p.DetailedLoanType),
+			Borrower:     normalizeTitle(p.LoanTitle),
 		}
 	}
 	return products
 }
 
-func convertCategory(loanType string) autop2p.Category {
-	switch loanType {
-	case "아파트담보":
-		return autop2p.MortgageRealEstate
-	default:
-		return autop2p.UNKNOWN
+func (s *ServiceImpl) GetProductDetail(ctx context.Context, product *autop2p.Product) error {
+	_, loanId, err := parseProductId(product.Id)
+	if err != nil {
+		return err
+	}
+
+	resp, err := s.api.GetProductDetail(ctx, loanId)
+	if err != nil {
+		return err
+	}
+
+	detail := resp.Data
+	if detail.LoanApplicationId != loanId {
+		return &autop2p.LayoutError{
+			Company: autop2p.Peoplefund,
+			Reason:  fmt.Sprintf("product detail for %d returned loan %d", loanId, detail.LoanApplicationId),
+		}
+	}
+
+	closingAt, err := parseTime(detail.InvestEndDatetime)
+	if err != nil {
+		return &autop2p.LayoutError{
+			Company: autop2p.Peoplefund,
+			Reason:  "can't parse invest_end_datetime from product detail",
+			Err:     err,
+		}
+	}
+
+	product.LTV = detail.Ltv
+	product.Grade = detail.CreditGrade
+	product.CollateralType = detail.CollateralType
+	product.CollateralValue = detail.CollateralValue
+	if detail.BorrowerId != 0 {
+		product.BorrowerId = strconv.Itoa(detail.BorrowerId)
+	}
+	product.GoalAmount = detail.LoanAmount
+	product.Progress = detail.InvestProgressRate
+	product.ClosingAt = closingAt
+	return nil
+}
+
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
 	}
+	return time.ParseInLocation("2006-01-02 15:04:05", value, util.KST)
 }
 
-func (s *ServiceImpl) Login(email string, password string) string {
-	return s.api.Login(email, password)
+func (s *ServiceImpl) Login(ctx context.Context, email string, password string) (string, error) {
+	return s.api.Login(ctx, email, password)
 }
 
-func (s *ServiceImpl) CheckAndInvest(sessionId string, productId string, amount int) *autop2p.InvestError {
-	slice := strings.Split(productId, "-")
-	loanId, _ := strconv.Atoi(slice[1])
-	err := s.checkInvestment(sessionId, loanId, amount)
+func (s *ServiceImpl) CheckInvestment(ctx context.Context, sessionId string, productId string, amount int) error {
+	_, loanId, err := parseProductId(productId)
 	if err != nil {
 		return err
 	}
-	s.api.Invest(sessionId, slice[0], loanId, amount, 0)
-	return nil
+	return s.checkInvestment(ctx, sessionId, loanId, amount)
 }
 
-func (s *ServiceImpl) checkInvestment(sessionId string, loanId int, amount int) *autop2p.InvestError {
-	info := s.api.CheckInvestment(sessionId, loanId)
+func (s *ServiceImpl) CheckAndInvest(ctx context.Context, sessionId string, productId string, amount int) error {
+	uri, loanId, err := parseProductId(productId)
+	if err != nil {
+		return err
+	}
+	err = s.checkInvestment(ctx, sessionId, loanId, amount)
+	if err != nil {
+		return err
+	}
+	res, err := s.api.Invest(ctx, sessionId, uri, loanId, amount, 0)
 
+	var outcome error
+	if err == nil && res.Status != successStatus {
+		outcome = autop2p.ParseInvestMessage(res.Message, investMessageCodes)
+	}
+	return autop2p.ConfirmInvestment(ctx, productId, err, outcome, func(ctx context.Context) ([]autop2p.Holding, error) {
+		return s.ListHoldings(ctx, sessionId)
+	})
+}
+
+const successStatus = "success"
+
+// investMessageCodes classifies refusal messages by keyword. Capacity and
+// closing come first, since a closed product's message like "이미 마감된 상품"
+// also has the duplicate keyword.
+var investMessageCodes = []autop2p.MessageCode{
+	{Keyword: "마감", Code: autop2p.InsufficientCapacity},
+	{Keyword: "모집", Code: autop2p.InsufficientCapacity},
+	{Keyword: "잔여", Code: autop2p.InsufficientCapacity},
+	{Keyword: "예치금", Code: autop2p.InsufficientBalance},
+	{Keyword: "잔액", Code: autop2p.InsufficientBalance},
+	{Keyword: "한도", Code: autop2p.LimitExceeded},
+	{Keyword: "이미", Code: autop2p.Duplicated},
+	{Keyword: "중복", Code: autop2p.Duplicated},
+}
+
+func parseProductId(productId string) (string, int, error) {
+	slice := strings.Split(productId, "-")
+	if len(slice) != 2 {
+		return "", 0, fmt.Errorf("invalid peoplefund product id %q", productId)
+	}
+	loanId, err := strconv.Atoi(slice[1])
+	if err != nil {
+		return "", 0, fmt.Errorf("invalid peoplefund product id %q: %w", productId, err)
+	}
+	return slice[0], loanId, nil
+}
+
+func (s *ServiceImpl) checkInvestment(ctx context.Context, sessionId string, loanId int, amount int) error {
+	info, err := s.api.CheckInvestment(ctx, sessionId, loanId)
+	if err != nil {
+		return err
+	}
+
+	available := min(info.Data.Cash, info.Data.MaxInvestableAmount)
 	if info.Data.Cash < amount {
-		return &autop2p.InvestError{Code: autop2p.InsufficientBalance}
+		return &autop2p.InvestError{Code: autop2p.InsufficientBalance, Available: available}
 	}
 	if info.Data.MaxInvestableAmount < amount {
-		return &autop2p.InvestError{Code: autop2p.InsufficientCapacity}
+		return &autop2p.InvestError{Code: autop2p.InsufficientCapacity, Available: available}
 	}
 	return nil
 }
 
-func (s *ServiceImpl) ListInvestedProductTitles(sessionId string) map[string]struct{} {
-	list := s.api.ListInvestedProducts(sessionId)
+func (s *ServiceImpl) GetBalance(ctx context.Context, sessionId string, productId string) (int, error) {
+
+	_, loanId, err := parseProductId(productId)
+	if err != nil {
+		return 0, err
+	}
+
+	info, err := s.api.CheckInvestment(ctx, sessionId, loanId)
+	if err != nil {
+		return 0, err
+	}
+	return info.Data.Cash, nil
+}
+
+func (s *ServiceImpl) ListInvestedProductTitles(ctx context.Context, sessionId string) (map[string]struct{}, error) {
+	list, err := s.api.ListInvestedProducts(ctx, sessionId)
+	if err != nil {
+		return nil, err
+	}
 
 	container := make(map[string]struct{})
 
-	matcher, _ := regexp.Compile("(-\\d+)?$")
+	for _, p := range list.Data.List {
+		if _, ok := closedStatuses[p.LoanApplicationStatus]; !ok {
+			container[normalizeTitle(p.Title)] = struct{}{}
+		}
+	}
+	return container, nil
+}
 
-	filter := map[string]struct{}{
-		"매각완료": {}, "채권종결": {}, "상환완료": {},
+func (s *ServiceImpl) ListHoldings(ctx context.Context, sessionId string) ([]autop2p.Holding, error) {
+	list, err := s.api.ListInvestedProducts(ctx, sessionId)
+	if err != nil {
+		return nil, err
 	}
 
+	var holdings []autop2p.Holding
 	for _, p := range list.Data.List {
-		if _, ok := filter[p.LoanApplicationStatus]; !ok {
-			container[strings.Trim(matcher.ReplaceAllString(p.Title, ""), " ")] = struct{}{}
+		if _, ok := closedStatuses[p.LoanApplicationStatus]; !ok {
+			holdings = append(holdings, autop2p.Holding{
+				ProductId: fmt.Sprintf("%s-%d", p.Uri, p.LoanApplicationId),
+				Title:     p.Title,
+				Category:  s.categories.convert(p.LoanType, p.DetailedLoanType),
+				Amount:    p.InvestAmount,
+				Borrower:  normalizeTitle(p.Title),
+			})
 		}
 	}
-	return container
+	return holdings, nil
+}
+
+var closedStatuses = map[string]struct{}{
+	"매각완료": {}, "채권종결": {}, "상환완료": {},
+}
+
+var titleSeqMatcher = regexp.MustCompile("(-\\d+)?$")
+
+func normalizeTitle(title string) string {
+	return strings.Trim(titleSeqMatcher.ReplaceAllString(title, ""), " ")
 }
@@ -1,39 +1,50 @@
 package peoplefund
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"testing"
+	"time"
 )
 
 type ApiMock struct {
 	mock.Mock
 }
 
-func (m *ApiMock) ListProducts(status string) *ListProductResponse {
-	args := m.Called(status)
-	return args.Get(0).(*ListProductResponse)
+func (m *ApiMock) ListProducts(ctx context.Context, status string, page int) (*ListProductResponse, error) {
+	args := m.Called(ctx, status, page)
+	return args.Get(0).(*ListProductResponse), args.Error(1)
 }
 
-func (m *ApiMock) Login(email string, password string) string {
-	args := m.Called(email, password)
-	return args.Get(0).(string)
+func (m *ApiMock) GetProductDetail(ctx context.Context, loanId int) (*ProductDetailResponse, error) {
+	args := m.Called(ctx, loanId)
+	return args.Get(0).(*ProductDetailResponse), args.Error(1)
 }
 
-func (m *ApiMock) Invest(sessionId string, uri string, loanId int, investAmount int, pointAmount int) {
-	m.Called(sessionId, uri, loanId, investAmount, pointAmount)
+func (m *ApiMock) Login(ctx context.Context, email string, password string) (string, error) {
+	args := m.Called(ctx, email, password)
+	return args.Get(0).(string), args.Error(1)
 }
 
-func (m *ApiMock) CheckInvestment(sessionId string, loanId int) *CheckInvestmentResponse {
-	args := m.Called(sessionId, loanId)
-	return args.Get(0).(*CheckInvestmentResponse)
+func (m *ApiMock) Invest(ctx context.Context, sessionId string, uri string, loanId int, investAmount int, pointAmount int) (*InvestResponse, error) {
+	args := m.Called(ctx, sessionId, uri, loanId, investAmount, pointAmount)
+	res, _ := args.Get(0).(*InvestResponse)
+	return res, args.Error(1)
 }
 
-func (m *ApiMock) ListInvestedProducts(sessionId string) *ListInvestedProductsResponse {
-	args := m.Called(sessionId)
-	return args.Get(0).(*ListInvestedProductsResponse)
+func (m *ApiMock) CheckInvestment(ctx context.Context, sessionId string, loanId int) (*CheckInvestmentResponse, error) {
+	args := m.Called(ctx, sessionId, loanId)
+	return args.Get(0).(*CheckInvestmentResponse), args.Error(1)
+}
+
+func (m *ApiMock) ListInvestedProducts(ctx context.Context, sessionId string) (*ListInvestedProductsResponse, error) {
+	args := m.Called(ctx, sessionId)
+	return args.Get(0).(*ListInvestedProductsResponse), args.Error(1)
 }
 
 func TestServiceImpl_ListProducts(t *testing.T) {
@@ -71,10 +82,11 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 	}
 
 	mockApi := &ApiMock{}
-	mockApi.On("ListProducts", "투자모집중").Return(resp)
+	mockApi.On("ListProducts", mock.Anything, "투자모집중", mock.Anything).Return(resp, nil)
 
-	s := NewService(mockApi)
-	p := s.ListProducts()
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	p, err := s.ListProducts(context.Background())
+	assert.Nil(t, err)
 	assert.Len(t, p, 2)
 	assert.Contains(t, p, autop2p.Product{
 		Id:           "ml4980-1",
@@ -84,6 +96,7 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 		Period:       12,
 		RemainAmount: 100000,
 		Category:     autop2p.MortgageRealEstate,
+		Borrower:     "아파트 담보(투자시 부자동) 2144",
 	})
 	assert.Contains(t, p, autop2p.Product{
 		Id:           "ml5053-2",
@@ -93,22 +106,112 @@ func TestServiceImpl_ListProducts(t *testing.T) {
 		Period:       9,
 		RemainAmount: 2000000,
 		Category:     autop2p.MortgageRealEstate,
+		Borrower:     "아파트 담보(투자시 벼락동) 2170",
 	})
 }
 
+func productPage(loanIds ...int) *ListProductResponse {
+	resp := &ListProductResponse{}
+	for _, loanId := range loanIds {
+		resp.Data.List = append(resp.Data.List, struct {
+			Uri                 string
+			LoanApplicationId   int     `json:"loan_application_id"`
+			LoanType            string  `json:"loan_type"`
+			DetailedLoanType    string  `json:"detailed_loan_type"`
+			InterestRate        float64 `json:"interest_rate"`
+			LoanApplicationTerm int     `json:"loan_application_term"`
+			RemainAmount        int     `json:"remain_amount"`
+			LoanTitle           string  `json:"loan_title"`
+		}{Uri: "ml", LoanApplicationId: loanId})
+	}
+	return resp
+}
+
+func TestServiceImpl_ListProducts_Pagination(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("ListProducts", mock.Anything, "투자모집중", 1).Return(productPage(1, 2), nil)
+	mockApi.On("ListProducts", mock.Anything, "투자모집중", 2).Return(productPage(2, 3), nil)
+	mockApi.On("ListProducts", mock.Anything, "투자모집중", 3).Return(productPage(), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	p, err := s.ListProducts(context.Background())
+
+	assert.Nil(t, err)
+	var ids []string
+	for _, product := range p {
+		ids = append(ids, product.Id)
+	}
+	assert.Equal(t, []string{"ml-1", "ml-2", "ml-3"}, ids)
+	mockApi.AssertNumberOfCalls(t, "ListProducts", 3)
+}
+
+func TestServiceImpl_ListProducts_MaxPages(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("ListProducts", mock.Anything, "투자모집중", 1).Return(productPage(1, 2), nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{MaxPages: 1}, nil)
+	p, err := s.ListProducts(context.Background())
+
+	assert.Nil(t, err)
+	assert.Len(t, p, 2)
+	mockApi.AssertNumberOfCalls(t, "ListProducts", 1)
+}
+
+func TestServiceImpl_GetProductDetail(t *testing.T) {
+	jsonString := `{
+	  "status": "success",
+	  "message": "success",
+	  "data": {
+		"loan_application_id": 2,
+		"ltv": 72.1,
+		"credit_grade": "",
+		"collateral_type": "아파트",
+		"collateral_value": 600000000,
+		"borrower_id": 3021,
+		"loan_amount": 50000000,
+		"invest_progress_rate": 35.5,
+		"invest_end_datetime": "2021-06-01 18:00:00"
+	  }
+	}`
+	resp := &ProductDetailResponse{}
+	if err := json.Unmarshal([]byte(jsonString), resp); err != nil {
+		panic(err)
+	}
+
+	mockApi := &ApiMock{}
+	mockApi.On("GetProductDetail", mock.Anything, 2).Return(resp, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	p := &autop2p.Product{Id: "ml5053-2"}
+	err := s.GetProductDetail(context.Background(), p)
+
+	assert.Nil(t, err)
+	assert.Equal(t, &autop2p.Product{
+		Id:              "ml5053-2",
+		LTV:             72.1,
+		CollateralType:  "아파트",
+		CollateralValue: 600000000,
+		BorrowerId:      "3021",
+		GoalAmount:      50000000,
+		Progress:        35.5,
+		ClosingAt:       time.Date(2021, 6, 1, 18, 0, 0, 0, util.KST),
+	}, p)
+}
+
 func TestServiceImpl_Login(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("Login", "email", "password").Return("SESSID")
+	mockApi.On("Login", mock.Anything, "email", "password").Return("SESSID", nil)
 
-	s := NewService(mockApi)
-	sessionId := s.Login("email", "password")
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	sessionId, err := s.Login(context.Background(), "email", "password")
 
+	assert.Nil(t, err)
 	assert.Equal(t, sessionId, "SESSID")
 }
 
 func TestServiceImpl_CheckAndInvest_InsufficientBalance(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("CheckInvestment", "sessionId", 1).Return(&CheckInvestmentResponse{
+	mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(&CheckInvestmentResponse{
 		Status:  "success",
 		Message: "success",
 		Data: struct {
@@ -118,17 +221,17 @@ func TestServiceImpl_CheckAndInvest_InsufficientBalance(t *testing.T) {
 			MaxInvestableAmount: 100000,
 			Cash:                0,
 		},
-	})
+	}, nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("sessionId", "ml1-1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	err := s.CheckAndInvest(context.Background(), "sessionId", "ml1-1", 10000)
 
-	assert.Equal(t, err.Code, autop2p.InsufficientBalance)
+	assert.Equal(t, &autop2p.InvestError{Code: autop2p.InsufficientBalance}, err)
 }
 
 func TestServiceImpl_CheckAndInvest_InsufficientCapacity(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("CheckInvestment", "sessionId", 1).Return(&CheckInvestmentResponse{
+	mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(&CheckInvestmentResponse{
 		Status:  "success",
 		Message: "success",
 		Data: struct {
@@ -138,17 +241,17 @@ func TestServiceImpl_CheckAndInvest_InsufficientCapacity(t *testing.T) {
 			MaxInvestableAmount: 0,
 			Cash:                100000,
 		},
-	})
+	}, nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("sessionId", "ml1-1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	err := s.CheckAndInvest(context.Background(), "sessionId", "ml1-1", 10000)
 
-	assert.Equal(t, err.Code, autop2p.InsufficientCapacity)
+	assert.Equal(t, &autop2p.InvestError{Code: autop2p.InsufficientCapacity}, err)
 }
 
 func TestServiceImpl_CheckAndInvest(t *testing.T) {
 	mockApi := &ApiMock{}
-	mockApi.On("CheckInvestment", "sessionId", 1).Return(&CheckInvestmentResponse{
+	mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(&CheckInvestmentResponse{
 		Status:  "success",
 		Message: "success",
 		Data: struct {
@@ -158,15 +261,107 @@ func TestServiceImpl_CheckAndInvest(t *testing.T) {
 			MaxInvestableAmount: 100000,
 			Cash:                100000,
 		},
-	})
-	mockApi.On("Invest", "sessionId", "ml1", 1, 10000, 0)
+	}, nil)
+	mockApi.On("Invest", mock.Anything, "sessionId", "ml1", 1, 10000, 0).Return(&InvestResponse{Status: "success"}, nil)
+	mockApi.On("ListInvestedProducts", mock.Anything, "sessionId").Return(investedList("ml1", 1), nil)
 
-	s := NewService(mockApi)
-	err := s.CheckAndInvest("sessionId", "ml1-1", 10000)
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	err := s.CheckAndInvest(context.Background(), "sessionId", "ml1-1", 10000)
 
 	assert.Nil(t, err)
 }
 
+func investedList(uri string, loanIds ...int) *ListInvestedProductsResponse {
+	resp := &ListInvestedProductsResponse{}
+	for _, id := range loanIds {
+		resp.Data.List = append(resp.Data.List, struct {
+			Uri                   string
+			Title                 string
+			LoanApplicationId     int    `json:"loan_application_id"`
+			LoanType              string `json:"loan_type"`
+			DetailedLoanType      string `json:"detailed_loan_type"`
+			LoanApplicationStatus string `json:"loan_application_status"`
+			InvestAmount          int    `json:"invest_amount"`
+		}{Uri: uri, LoanApplicationId: id, LoanApplicationStatus: "정상"})
+	}
+	return resp
+}
+
+func TestServiceImpl_CheckAndInvest_Confirmation(t *testing.T) {
+	check := &CheckInvestmentResponse{Status: "success"}
+	check.Data.MaxInvestableAmount = 100000
+	check.Data.Cash = 100000
+
+	tests := []struct {
+		name      string
+		res       *InvestResponse
+		investErr error
+		holdings  *ListInvestedProductsResponse
+		code      string
+		err       string
+	}{
+		{
+			name:     "soft failure",
+			res:      &InvestResponse{Status: "fail", Message: "예치금이 부족합니다."},
+			holdings: investedList("ml1"),
+			code:     autop2p.InsufficientBalance,
+			err:      "Insufficient balance: 예치금이 부족합니다.",
+		},
+		{
+			name:     "success not in holdings",
+			res:      &InvestResponse{Status: "success"},
+			holdings: investedList("ml1", 2),
+			code:     autop2p.Unconfirmed,
+			err:      "investment unconfirmed: response succeeded but product is not in holdings",
+		},
+		{
+			name:      "unreadable response in holdings",
+			investErr: &util.DecodeError{Url: "https://www.peoplefund.co.kr/showcase/investSubmitAjax"},
+			holdings:  investedList("ml1", 1),
+		},
+		{
+			name:      "unreadable response not in holdings",
+			investErr: &util.DecodeError{Url: "https://www.peoplefund.co.kr/showcase/investSubmitAjax"},
+			holdings:  investedList("ml1"),
+			code:      autop2p.Unconfirmed,
+			err:       `investment unconfirmed: request failed with "failed to decode response from https://www.peoplefund.co.kr/showcase/investSubmitAjax: <nil>" and product not in holdings`,
+		},
+		{
+			name:      "server error in holdings",
+			investErr: &util.StatusError{StatusCode: 502, Url: "https://www.peoplefund.co.kr/showcase/investSubmitAjax"},
+			holdings:  investedList("ml1", 1),
+		},
+		{
+			name:      "timeout not in holdings",
+			investErr: context.DeadlineExceeded,
+			holdings:  investedList("ml1"),
+			code:      autop2p.Unconfirmed,
+			err:       `investment unconfirmed: request failed with "context deadline exceeded" and product not in holdings`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockApi := &ApiMock{}
+			mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(check, nil)
+			mockApi.On("Invest", mock.Anything, "sessionId", "ml1", 1, 10000, 0).Return(tt.res, tt.investErr)
+			mockApi.On("ListInvestedProducts", mock.Anything, "sessionId").Return(tt.holdings, nil)
+
+			s := NewService(mockApi, autop2p.PagingConf{}, nil)
+			err := s.CheckAndInvest(context.Background(), "sessionId", "ml1-1", 10000)
+
+			if tt.code == "" {
+				assert.Nil(t, err)
+				return
+			}
+			var investErr *autop2p.InvestError
+			assert.True(t, errors.As(err, &investErr))
+			assert.Equal(t, tt.code, investErr.Code)
+			assert.EqualError(t, err, tt.err)
+		})
+	}
+}
+
 func TestServiceImpl_ListInvestedProductTitles(t *testing.T) {
 	jsonString := `{
 	  "status": "success",
@@ -210,13 +405,117 @@ func TestServiceImpl_ListInvestedProductTitles(t *testing.T) {
 	}
 
 	mockApi := &ApiMock{}
-	mockApi.On("ListInvestedProducts", mock.Anything).Return(resp)
+	mockApi.On("ListInvestedProducts", mock.Anything, mock.Anything).Return(resp, nil)
 
-	s := NewService(mockApi)
-	ret := s.ListInvestedProductTitles("sessionId")
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	ret, err := s.ListInvestedProductTitles(context.Background(), "sessionId")
 
+	assert.Nil(t, err)
 	assert.Len(t, ret, 3)
 	assert.Contains(t, ret, "아파트 담보(투자시 부자동) 2144")
 	assert.Contains(t, ret, "아파트 담보(투자시 손실동) 144")
 	assert.Contains(t, ret, "아파트 담보(투자시 세배동) 1057")
 }
+
+func TestServiceImpl_CheckInvestment(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(&CheckInvestmentResponse{
+		Status:  "success",
+		Message: "success",
+		Data: struct {
+			MaxInvestableAmount int `json:"max_investable_amount"`
+			Cash                int
+		}{
+			MaxInvestableAmount: 100000,
+			Cash:                100000,
+		},
+	}, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	err := s.CheckInvestment(context.Background(), "sessionId", "ml1-1", 10000)
+
+	assert.Nil(t, err)
+	mockApi.AssertNotCalled(t, "Invest", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+}
+
+func TestServiceImpl_ListHoldings(t *testing.T) {
+	jsonString := `{
+	  "status": "success",
+	  "message": "success",
+	  "data": {
+		"list": [
+		  {
+			"uri": "ml4980",
+			"loan_application_id": 1,
+			"loan_type": "아파트담보",
+			"title": "아파트 담보(투자시 부자동) 2144-1",
+			"loan_application_status": "상환중",
+			"invest_amount": 10000
+		  },
+		  {
+			"uri": "ml4981",
+			"loan_application_id": 2,
+			"loan_type": "아파트담보",
+			"title": "아파트 담보(투자시 세배동) 1057-1",
+			"loan_application_status": "상환완료",
+			"invest_amount": 20000
+		  }
+		]
+	  }
+	}`
+	resp := &ListInvestedProductsResponse{}
+	if err := json.Unmarshal([]byte(jsonString), resp); err != nil {
+		panic(err)
+	}
+
+	mockApi := &ApiMock{}
+	mockApi.On("ListInvestedProducts", mock.Anything, "sessionId").Return(resp, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	ret, err := s.ListHoldings(context.Background(), "sessionId")
+
+	assert.Nil(t, err)
+	assert.Equal(t, []autop2p.Holding{
+		{
+			ProductId: "ml4980-1",
+			Title:     "아파트 담보(투자시 부자동) 2144-1",
+			Category:  autop2p.MortgageRealEstate,
+			Amount:    10000,
+			Borrower:  "아파트 담보(투자시 부자동) 2144",
+		},
+	}, ret)
+}
+
+func TestServiceImpl_GetBalance(t *testing.T) {
+	mockApi := &ApiMock{}
+	mockApi.On("CheckInvestment", mock.Anything, "sessionId", 1).Return(&CheckInvestmentResponse{
+		Status:  "success",
+		Message: "success",
+		Data: struct {
+			MaxInvestableAmount int `json:"max_investable_amount"`
+			Cash                int
+		}{
+			MaxInvestableAmount: 100000,
+			Cash:                123000,
+		},
+	}, nil)
+
+	s := NewService(mockApi, autop2p.PagingConf{}, nil)
+	balance, err := s.GetBalance(context.Background(), "sessionId", "ml4980-1")
+
+	assert.Nil(t, err)
+	assert.Equal(t, 123000, balance)
+}
+
+func TestInvestMessageCodes(t *testing.T) {
+	for message, code := range map[string]string{
+		"이미 마감된 상품입니다.":   autop2p.InsufficientCapacity,
+		"모집 금액을 초과했습니다.":  autop2p.InsufficientCapacity,
+		"이미 투자한 상품입니다.":   autop2p.Duplicated,
+		"예치금이 부족합니다.":     autop2p.InsufficientBalance,
+		"투자 한도를 초과했습니다.":  autop2p.LimitExceeded,
+		"잠시 후 다시 시도해주세요.": autop2p.Rejected,
+	} {
+		assert.Equal(t, code, autop2p.ParseInvestMessage(message, investMessageCodes).Code, message)
+	}
+}
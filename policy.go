@@ -0,0 +1,61 @@
+package autop2p
+
+import "errors"
+
+// Action tells the run what to do after an investment attempt fails.
+type Action string
+
+const (
+	// Continue moves on to the next candidate.
+	Continue Action = "continue"
+	// StopSetting skips the remaining candidates of the setting.
+	StopSetting Action = "stopSetting"
+	// StopCompany also skips the remaining settings of the same company.
+	StopCompany Action = "stopCompany"
+	// AbortRun skips every remaining setting.
+	AbortRun Action = "abortRun"
+)
+
+var actions = map[Action]struct{}{
+	Continue:    {},
+	StopSetting: {},
+	StopCompany: {},
+	AbortRun:    {},
+}
+
+var investErrorCodes = map[string]struct{}{
+	Duplicated:           {},
+	InsufficientCapacity: {},
+	InsufficientBalance:  {},
+	LimitExceeded:        {},
+	Rejected:             {},
+	Unconfirmed:          {},
+}
+
+// Policy maps InvestError codes to the action taken when they occur.
+type Policy map[string]Action
+
+var defaultPolicy = Policy{
+	Duplicated:           Continue,
+	InsufficientCapacity: Continue,
+	LimitExceeded:        Continue,
+	InsufficientBalance:  StopSetting,
+	Unconfirmed:          Continue,
+}
+
+// ActionFor returns the action for err. Codes missing from the setting's
+// Policy fall back to the default policy, and errors that are not an
+// InvestError or have an unknown code stop the setting.
+func (s *Setting) ActionFor(err error) Action {
+	var investErr *InvestError
+	if !errors.As(err, &investErr) {
+		return StopSetting
+	}
+	if action, ok := s.Policy[investErr.Code]; ok {
+		return action
+	}
+	if action, ok := defaultPolicy[investErr.Code]; ok {
+		return action
+	}
+	return StopSetting
+}
@@ -0,0 +1,28 @@
+package autop2p
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetting_ActionFor(t *testing.T) {
+	s := &Setting{Policy: Policy{InsufficientBalance: AbortRun, Duplicated: StopCompany}}
+
+	tests := []struct {
+		err    error
+		action Action
+	}{
+		{&InvestError{Code: InsufficientBalance}, AbortRun},
+		{&InvestError{Code: Duplicated}, StopCompany},
+		{&InvestError{Code: InsufficientCapacity}, Continue},
+		{&InvestError{Code: LimitExceeded}, Continue},
+		{&InvestError{Code: "Unknown"}, StopSetting},
+		{errors.New("network down"), StopSetting},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.action, s.ActionFor(tt.err), tt.err.Error())
+	}
+
+	assert.Equal(t, StopSetting, (&Setting{}).ActionFor(&InvestError{Code: InsufficientBalance}))
+}
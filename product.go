@@ -1,5 +1,7 @@
 package autop2p
 
+import "time"
+
 type Product struct {
 	Id           string
 	Company      CompanyType
@@ -8,4 +10,24 @@ type Product struct {
 	Period       int
 	RemainAmount int
 	Category     Category
+	Borrower     string
+
+	// Filled in from the listing when the platform has them, otherwise by
+	// Runner.LoadDetail. Zero values mean unknown.
+	LTV             float64
+	Grade           string
+	CollateralType  string
+	CollateralValue int
+	BorrowerId      string
+	GoalAmount      int
+	Progress        float64
+	ClosingAt       time.Time
+}
+
+type Holding struct {
+	ProductId string
+	Title     string
+	Category  Category
+	Amount    int
+	Borrower  string
 }
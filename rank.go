@@ -0,0 +1,60 @@
+package autop2p
+
+import "sort"
+
+type SortStrategy string
+
+const (
+	SortRate      SortStrategy = "rate"
+	SortPeriod    SortStrategy = "period"
+	SortClosing   SortStrategy = "closing"
+	SortRemaining SortStrategy = "remaining"
+	SortScore     SortStrategy = "score"
+)
+
+// ScoreWeights weigh product fields for SortScore. Remaining is counted in
+// millions of won so its weight is on the same scale as the others.
+type ScoreWeights struct {
+	Rate      float64
+	Period    float64
+	Remaining float64
+	Progress  float64
+	LTV       float64 `yaml:"ltv"`
+}
+
+func (w *ScoreWeights) score(p *Product) float64 {
+	return w.Rate*p.Rate +
+		w.Period*float64(p.Period) +
+		w.Remaining*float64(p.RemainAmount)/1000000 +
+		w.Progress*p.Progress +
+		w.LTV*p.LTV
+}
+
+// Rank orders products so the preferred ones are tried first. Ties keep the
+// platform order, and an empty Sort keeps it entirely.
+func (s *Setting) Rank(products []Product) {
+	var less func(a, b *Product) bool
+	switch s.Sort {
+	case SortRate:
+		less = func(a, b *Product) bool { return a.Rate > b.Rate }
+	case SortPeriod:
+		less = func(a, b *Product) bool { return a.Period < b.Period }
+	case SortClosing:
+		less = func(a, b *Product) bool {
+			if a.ClosingAt.IsZero() || b.ClosingAt.IsZero() {
+				return !a.ClosingAt.IsZero() && b.ClosingAt.IsZero()
+			}
+			return a.ClosingAt.Before(b.ClosingAt)
+		}
+	case SortRemaining:
+		less = func(a, b *Product) bool { return a.RemainAmount > b.RemainAmount }
+	case SortScore:
+		less = func(a, b *Product) bool { return s.Weights.score(a) > s.Weights.score(b) }
+	default:
+		return
+	}
+
+	sort.SliceStable(products, func(i, j int) bool {
+		return less(&products[i], &products[j])
+	})
+}
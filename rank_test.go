@@ -0,0 +1,49 @@
+package autop2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+	"time"
+)
+
+func rankedIds(s *Setting, products []Product) []string {
+	s.Rank(products)
+	ids := make([]string, len(products))
+	for i, p := range products {
+		ids[i] = p.Id
+	}
+	return ids
+}
+
+func TestSetting_Rank(t *testing.T) {
+	now := time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)
+	products := func() []Product {
+		return []Product{
+			{Id: "a", Rate: 10, Period: 12, RemainAmount: 5000000, ClosingAt: now.Add(48 * time.Hour)},
+			{Id: "b", Rate: 14, Period: 6, RemainAmount: 1000000},
+			{Id: "c", Rate: 10, Period: 3, RemainAmount: 30000000, ClosingAt: now.Add(2 * time.Hour)},
+		}
+	}
+
+	cases := []struct {
+		setting  Setting
+		expected []string
+	}{
+		{Setting{}, []string{"a", "b", "c"}},
+		{Setting{Sort: SortRate}, []string{"b", "a", "c"}},
+		{Setting{Sort: SortPeriod}, []string{"c", "b", "a"}},
+		{Setting{Sort: SortClosing}, []string{"c", "a", "b"}},
+		{Setting{Sort: SortRemaining}, []string{"c", "a", "b"}},
+		{Setting{Sort: SortScore, Weights: ScoreWeights{Rate: 1, Period: -0.5}}, []string{"b", "c", "a"}},
+	}
+	for _, c := range cases {
+		assert.Equal(t, c.expected, rankedIds(&c.setting, products()), string(c.setting.Sort))
+	}
+}
+
+func TestSetting_NeedsDetail_Weights(t *testing.T) {
+	assert.False(t, (&Setting{Sort: SortScore, Weights: ScoreWeights{Rate: 1, Remaining: 1}}).NeedsDetail())
+	assert.True(t, (&Setting{Sort: SortScore, Weights: ScoreWeights{Progress: 1}}).NeedsDetail())
+	assert.True(t, (&Setting{Sort: SortScore, Weights: ScoreWeights{LTV: -1}}).NeedsDetail())
+	assert.False(t, (&Setting{Sort: SortRate, Weights: ScoreWeights{Progress: 1}}).NeedsDetail())
+}
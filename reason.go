@@ -0,0 +1,34 @@
+package autop2p
+
+import "fmt"
+
+const (
+	NoAmount               = "NoAmount"
+	AmountExceedsRemaining = "AmountExceedsRemaining"
+	PeriodOutOfRange       = "PeriodOutOfRange"
+	RateOutOfRange         = "RateOutOfRange"
+	CategoryNotAllowed     = "CategoryNotAllowed"
+	LtvTooHigh             = "LtvTooHigh"
+	GradeNotAllowed        = "GradeNotAllowed"
+	CollateralNotAllowed   = "CollateralNotAllowed"
+	ProgressTooLow         = "ProgressTooLow"
+	RejectedByRule         = "RejectedByRule"
+	AlreadyInvested        = "AlreadyInvested"
+	RejectedByPreCheck     = "RejectedByPreCheck"
+	// DetailUnavailable means the detail a filter needs couldn't be loaded.
+	DetailUnavailable = "DetailUnavailable"
+)
+
+type Reason struct {
+	Code   string
+	Detail string
+}
+
+func (r Reason) String() string {
+	return fmt.Sprintf("%s: %s", r.Code, r.Detail)
+}
+
+type Rejection struct {
+	Product Product
+	Reasons []Reason
+}
@@ -0,0 +1,99 @@
+package autop2p
+
+import (
+	"github.com/expr-lang/expr"
+	"github.com/expr-lang/expr/ast"
+	"github.com/expr-lang/expr/vm"
+	"time"
+)
+
+// ruleEnv is what a rule sees of a Product. Company and Category are plain
+// strings so rules can compare them with literals like "PF".
+type ruleEnv struct {
+	Id              string
+	Company         string
+	Title           string
+	Rate            float64
+	Period          int
+	RemainAmount    int
+	Category        string
+	Borrower        string
+	LTV             float64
+	Grade           string
+	CollateralType  string
+	CollateralValue int
+	BorrowerId      string
+	GoalAmount      int
+	Progress        float64
+	ClosingAt       time.Time
+}
+
+func newRuleEnv(p *Product) ruleEnv {
+	return ruleEnv{
+		Id:              p.Id,
+		Company:         string(p.Company),
+		Title:           p.Title,
+		Rate:            p.Rate,
+		Period:          p.Period,
+		RemainAmount:    p.RemainAmount,
+		Category:        string(p.Category),
+		Borrower:        p.Borrower,
+		LTV:             p.LTV,
+		Grade:           p.Grade,
+		CollateralType:  p.CollateralType,
+		CollateralValue: p.CollateralValue,
+		BorrowerId:      p.BorrowerId,
+		GoalAmount:      p.GoalAmount,
+		Progress:        p.Progress,
+		ClosingAt:       p.ClosingAt,
+	}
+}
+
+var detailFields = map[string]struct{}{
+	"LTV": {}, "Grade": {}, "CollateralType": {}, "CollateralValue": {},
+	"BorrowerId": {}, "GoalAmount": {}, "Progress": {}, "ClosingAt": {},
+}
+
+type rule struct {
+	program     *vm.Program
+	needsDetail bool
+}
+
+// compileRule type-checks source against ruleEnv, so a typo in a field name or
+// a non-boolean rule fails when the conf is loaded rather than mid-run.
+func compileRule(source string) (*rule, error) {
+	program, err := expr.Compile(source, expr.Env(ruleEnv{}), expr.AsBool())
+	if err != nil {
+		return nil, err
+	}
+
+	collector := &identifierCollector{}
+	node := program.Node()
+	ast.Walk(&node, collector)
+
+	r := &rule{program: program}
+	for _, name := range collector.names {
+		if _, ok := detailFields[name]; ok {
+			r.needsDetail = true
+		}
+	}
+	return r, nil
+}
+
+func (r *rule) match(product *Product) bool {
+	out, err := expr.Run(r.program, newRuleEnv(product))
+	if err != nil {
+		return false
+	}
+	return out.(bool)
+}
+
+type identifierCollector struct {
+	names []string
+}
+
+func (c *identifierCollector) Visit(node *ast.Node) {
+	if n, ok := (*node).(*ast.IdentifierNode); ok {
+		c.names = append(c.names, n.Value)
+	}
+}
@@ -0,0 +1,66 @@
+package autop2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestSetting_Match_Rule(t *testing.T) {
+	s := &Setting{
+		Amount: 10000,
+		Rule: `(Category == "PersonalCredit" && Rate >= 12) ||
+			(Category == "PF" && Period <= 6) ||
+			(Category == "CorporateCredit" && not (Title contains "SCF"))`,
+	}
+	assert.Nil(t, s.CompileRule())
+	assert.False(t, s.NeedsDetail())
+
+	products := map[string]bool{
+		"PersonalCredit 13%": true,
+		"PersonalCredit 11%": false,
+		"PF 6m":              true,
+		"PF 12m":             false,
+		"Corporate":          true,
+		"Corporate SCF":      false,
+		"Mortgage":           false,
+	}
+	cases := map[string]Product{
+		"PersonalCredit 13%": {Category: PersonalCredit, Rate: 13, Period: 12},
+		"PersonalCredit 11%": {Category: PersonalCredit, Rate: 11, Period: 12},
+		"PF 6m":              {Category: PF, Rate: 10, Period: 6},
+		"PF 12m":             {Category: PF, Rate: 10, Period: 12},
+		"Corporate":          {Category: CorporateCredit, Title: "매출채권 1호", Rate: 8, Period: 3},
+		"Corporate SCF":      {Category: CorporateCredit, Title: "SCF 플러스", Rate: 8, Period: 3},
+		"Mortgage":           {Category: MortgageRealEstate, Rate: 20, Period: 1},
+	}
+	for name, p := range cases {
+		p.RemainAmount = 100000
+		assert.Equal(t, products[name], s.Match(&p), name)
+	}
+}
+
+func TestSetting_Match_RuleAlongsideFields(t *testing.T) {
+	s := &Setting{
+		Amount:     10000,
+		PeriodMax:  12,
+		RateMax:    15,
+		Categories: []Category{MortgageRealEstate},
+		Rule:       "LTV <= 60",
+	}
+	assert.Nil(t, s.CompileRule())
+	assert.True(t, s.NeedsDetail())
+
+	assert.True(t, s.Match(&Product{RemainAmount: 100000, Period: 6, Rate: 10, Category: MortgageRealEstate, LTV: 55}))
+	assert.False(t, s.Match(&Product{RemainAmount: 100000, Period: 6, Rate: 10, Category: MortgageRealEstate, LTV: 65}))
+	assert.False(t, s.Match(&Product{RemainAmount: 100000, Period: 6, Rate: 10, Category: PF, LTV: 55}))
+}
+
+func TestSetting_CompileRule_Errors(t *testing.T) {
+	for _, source := range []string{"Rte > 10", "Rate + 1", "Rate >"} {
+		s := &Setting{Rule: source}
+		assert.NotNil(t, s.CompileRule(), source)
+	}
+
+	s := &Setting{Amount: 10000, Rule: "Rate > 10"}
+	assert.False(t, s.Match(&Product{RemainAmount: 100000, Rate: 12}), "uncompiled rule never matches")
+}
@@ -1,21 +1,45 @@
 package autop2p
 
+import "context"
+
 type Runner interface {
-	ListProducts() []Product
-	InvestProduct(product *Product, amount int) *InvestError
+	ListProducts(ctx context.Context) ([]Product, []Rejection, error)
+	LoadDetail(ctx context.Context, product *Product) error
+	CheckProduct(ctx context.Context, product *Product, amount int) error
+	InvestProduct(ctx context.Context, product *Product, amount int) error
+	ListHoldings(ctx context.Context) ([]Holding, error)
+	Balance(ctx context.Context) (int, error)
 }
 
 type InvestError struct {
 	Code string
+	// Available is the largest amount the check would have allowed, for
+	// InsufficientCapacity, InsufficientBalance and LimitExceeded.
+	Available int
+	// Message is the platform's explanation or, for Unconfirmed, what disagreed.
+	Message string
 }
 
 const (
 	Duplicated           = "Duplicated"
 	InsufficientCapacity = "InsufficientCapacity"
 	InsufficientBalance  = "InsufficientBalance"
+	LimitExceeded        = "LimitExceeded"
+	// Rejected is an invest response refusing for a reason not covered above.
+	Rejected = "Rejected"
+	// Unconfirmed means the invest response and the holdings listing disagree,
+	// so the money may or may not be committed.
+	Unconfirmed = "Unconfirmed"
 )
 
 func (err *InvestError) Error() string {
+	if err.Message != "" {
+		return err.describe() + ": " + err.Message
+	}
+	return err.describe()
+}
+
+func (err *InvestError) describe() string {
 	switch err.Code {
 	case Duplicated:
 		return "duplicated investment"
@@ -23,6 +47,12 @@ func (err *InvestError) Error() string {
 		return "insufficient residual capacity"
 	case InsufficientBalance:
 		return "Insufficient balance"
+	case LimitExceeded:
+		return "investor limit exceeded"
+	case Rejected:
+		return "investment rejected"
+	case Unconfirmed:
+		return "investment unconfirmed"
 	default:
 		return "unsupported InvestError Code"
 	}
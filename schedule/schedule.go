@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"context"
+	"github.com/robfig/cron/v3"
+	"sync"
+	"time"
+)
+
+type Scheduler struct {
+	cron *cron.Cron
+	ctx  context.Context
+	// running lets one job run at a time, so jobs sharing an account never
+	// invest concurrently. Jobs firing together wait for it in turn.
+	running sync.Mutex
+	mu      sync.Mutex
+	// pending holds the names of the jobs waiting or running.
+	pending map[string]struct{}
+	jobs    sync.WaitGroup
+	skipped func(name string)
+}
+
+func New(ctx context.Context, loc *time.Location, skipped func(name string)) *Scheduler {
+	return &Scheduler{
+		cron:    cron.New(cron.WithLocation(loc)),
+		ctx:     ctx,
+		pending: make(map[string]struct{}),
+		skipped: skipped,
+	}
+}
+
+func (s *Scheduler) Add(spec string, name string, run func(ctx context.Context)) error {
+	_, err := s.cron.AddFunc(spec, func() {
+		s.runExclusive(name, run)
+	})
+	return err
+}
+
+// runExclusive queues run behind the running job and skips it only when the
+// same job is still waiting or running. run gets the scheduler's context only
+// to stop starting new work; it must finish what is in flight on its own.
+func (s *Scheduler) runExclusive(name string, run func(ctx context.Context)) {
+	s.jobs.Add(1)
+	defer s.jobs.Done()
+
+	s.mu.Lock()
+	if _, ok := s.pending[name]; ok {
+		s.mu.Unlock()
+		s.skipped(name)
+		return
+	}
+	s.pending[name] = struct{}{}
+	s.mu.Unlock()
+	defer func() {
+		s.mu.Lock()
+		delete(s.pending, name)
+		s.mu.Unlock()
+	}()
+
+	s.running.Lock()
+	defer s.running.Unlock()
+
+	if s.ctx.Err() != nil {
+		return
+	}
+	run(s.ctx)
+}
+
+func (s *Scheduler) Next() time.Time {
+	var next time.Time
+	for _, e := range s.cron.Entries() {
+		if next.IsZero() || e.Next.Before(next) {
+			next = e.Next
+		}
+	}
+	return next
+}
+
+// Run fires the jobs until the context is done, then waits for the running
+// job to return.
+func (s *Scheduler) Run() {
+	s.cron.Start()
+	<-s.ctx.Done()
+	<-s.cron.Stop().Done()
+	s.jobs.Wait()
+}
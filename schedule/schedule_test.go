@@ -0,0 +1,156 @@
+package schedule
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestScheduler_Add_InvalidSpec(t *testing.T) {
+	s := New(context.Background(), time.UTC, func(name string) {})
+
+	assert.Error(t, s.Add("0 13 * *", "invalid", func(ctx context.Context) {}))
+}
+
+func TestScheduler_Next_TimeZone(t *testing.T) {
+	kst, err := time.LoadLocation("Asia/Seoul")
+	assert.Nil(t, err)
+
+	for spec, hour := range map[string]int{
+		"0 13 * * *":             4,
+		"CRON_TZ=UTC 0 4 * * *":  4,
+		"CRON_TZ=UTC 0 13 * * *": 13,
+	} {
+		s := New(context.Background(), kst, func(name string) {})
+		assert.Nil(t, s.Add(spec, spec, func(ctx context.Context) {}))
+
+		s.cron.Start()
+		next := s.Next()
+		s.cron.Stop()
+
+		assert.Equal(t, hour, next.UTC().Hour(), spec)
+		assert.Equal(t, 0, next.Minute(), spec)
+	}
+}
+
+func TestScheduler_RunExclusive_SkipsWhileRunning(t *testing.T) {
+	var skipped []string
+	s := New(context.Background(), time.UTC, func(name string) {
+		skipped = append(skipped, name)
+	})
+
+	started, release := make(chan struct{}), make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		s.runExclusive("first", func(ctx context.Context) {
+			close(started)
+			<-release
+		})
+		close(done)
+	}()
+	<-started
+
+	ran := false
+	s.runExclusive("first", func(ctx context.Context) {
+		ran = true
+	})
+	close(release)
+	<-done
+
+	assert.False(t, ran)
+	assert.Equal(t, []string{"first"}, skipped)
+
+	s.runExclusive("first", func(ctx context.Context) {
+		ran = true
+	})
+	assert.True(t, ran)
+}
+
+func TestScheduler_RunExclusive_QueuesOtherJobs(t *testing.T) {
+	var skipped []string
+	s := New(context.Background(), time.UTC, func(name string) {
+		skipped = append(skipped, name)
+	})
+
+	// Two entries firing on the same tick both run, one after the other.
+	var mu sync.Mutex
+	var ran []string
+	running := 0
+	var wg sync.WaitGroup
+	for _, name := range []string{"0 13 * * *", "CRON_TZ=UTC 0 4 * * *"} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.runExclusive(name, func(ctx context.Context) {
+				mu.Lock()
+				running++
+				assert.Equal(t, 1, running, "jobs run one at a time")
+				mu.Unlock()
+
+				time.Sleep(10 * time.Millisecond)
+
+				mu.Lock()
+				running--
+				ran = append(ran, name)
+				mu.Unlock()
+			})
+		}()
+	}
+	wg.Wait()
+
+	assert.ElementsMatch(t, []string{"0 13 * * *", "CRON_TZ=UTC 0 4 * * *"}, ran)
+	assert.Empty(t, skipped)
+}
+
+func TestScheduler_Run_StopsOnCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, time.UTC, func(name string) {})
+	assert.Nil(t, s.Add("* * * * *", "every minute", func(ctx context.Context) {}))
+
+	done := make(chan struct{})
+	go func() {
+		s.Run()
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didn't stop")
+	}
+}
+
+func TestScheduler_Run_WaitsForRunningJob(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	s := New(ctx, time.UTC, func(name string) {})
+
+	started, finished := make(chan struct{}), make(chan struct{})
+	go s.runExclusive("job", func(ctx context.Context) {
+		close(started)
+		<-ctx.Done()
+		time.Sleep(10 * time.Millisecond)
+		close(finished)
+	})
+	<-started
+
+	done := make(chan struct{})
+	go func() {
+		s.Run()
+		close(done)
+	}()
+	cancel()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("scheduler didn't stop")
+	}
+	select {
+	case <-finished:
+	default:
+		t.Fatal("scheduler stopped before the running job finished")
+	}
+}
@@ -0,0 +1,46 @@
+package secret
+
+import (
+	"bytes"
+	"context"
+	"filippo.io/age"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+type AgeFileBackend struct {
+	identityFile string
+}
+
+func NewAgeFileBackend(identityFile string) *AgeFileBackend {
+	return &AgeFileBackend{identityFile: identityFile}
+}
+
+func (b *AgeFileBackend) Get(ctx context.Context, key string) (string, error) {
+	identityFile, err := os.Open(b.identityFile)
+	if err != nil {
+		return "", err
+	}
+	defer identityFile.Close()
+
+	identities, err := age.ParseIdentities(identityFile)
+	if err != nil {
+		return "", err
+	}
+
+	data, err := ioutil.ReadFile(key)
+	if err != nil {
+		return "", err
+	}
+
+	r, err := age.Decrypt(bytes.NewReader(data), identities...)
+	if err != nil {
+		return "", err
+	}
+	plain, err := ioutil.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimRight(string(plain), "\r\n"), nil
+}
@@ -0,0 +1,64 @@
+package secret
+
+import (
+	"context"
+	"filippo.io/age"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestAgeFileBackend_Get(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+	identityFile := filepath.Join(dir, "identity.txt")
+	assert.Nil(t, ioutil.WriteFile(identityFile, []byte(identity.String()+"\n"), 0600))
+
+	secretFile := filepath.Join(dir, "hf.age")
+	f, err := os.Create(secretFile)
+	assert.Nil(t, err)
+	w, err := age.Encrypt(f, identity.Recipient())
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("hf-password\n"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.Nil(t, f.Close())
+
+	b := NewAgeFileBackend(identityFile)
+	value, err := b.Get(context.Background(), secretFile)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "hf-password", value)
+}
+
+func TestAgeFileBackend_Get_WrongIdentity(t *testing.T) {
+	dir := t.TempDir()
+
+	identity, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+	other, err := age.GenerateX25519Identity()
+	assert.Nil(t, err)
+
+	identityFile := filepath.Join(dir, "identity.txt")
+	assert.Nil(t, ioutil.WriteFile(identityFile, []byte(other.String()+"\n"), 0600))
+
+	secretFile := filepath.Join(dir, "hf.age")
+	f, err := os.Create(secretFile)
+	assert.Nil(t, err)
+	w, err := age.Encrypt(f, identity.Recipient())
+	assert.Nil(t, err)
+	_, err = w.Write([]byte("hf-password"))
+	assert.Nil(t, err)
+	assert.Nil(t, w.Close())
+	assert.Nil(t, f.Close())
+
+	value, err := NewAgeFileBackend(identityFile).Get(context.Background(), secretFile)
+
+	assert.Error(t, err)
+	assert.Empty(t, value)
+	assert.NotContains(t, err.Error(), "hf-password")
+}
@@ -0,0 +1,76 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"strings"
+)
+
+type SSMApi interface {
+	GetParameter(ctx context.Context, params *ssm.GetParameterInput, optFns ...func(*ssm.Options)) (*ssm.GetParameterOutput, error)
+}
+
+type SSMBackend struct {
+	client SSMApi
+}
+
+func NewSSMBackend(client SSMApi) *SSMBackend {
+	return &SSMBackend{client: client}
+}
+
+func (b *SSMBackend) Get(ctx context.Context, key string) (string, error) {
+	out, err := b.client.GetParameter(ctx, &ssm.GetParameterInput{
+		Name:           aws.String(key),
+		WithDecryption: aws.Bool(true),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.Parameter == nil || out.Parameter.Value == nil {
+		return "", fmt.Errorf("parameter %s has no value", key)
+	}
+	return *out.Parameter.Value, nil
+}
+
+type SecretsManagerApi interface {
+	GetSecretValue(ctx context.Context, params *secretsmanager.GetSecretValueInput, optFns ...func(*secretsmanager.Options)) (*secretsmanager.GetSecretValueOutput, error)
+}
+
+type SecretsManagerBackend struct {
+	client SecretsManagerApi
+}
+
+func NewSecretsManagerBackend(client SecretsManagerApi) *SecretsManagerBackend {
+	return &SecretsManagerBackend{client: client}
+}
+
+func (b *SecretsManagerBackend) Get(ctx context.Context, key string) (string, error) {
+	id, field, hasField := strings.Cut(key, "#")
+
+	out, err := b.client.GetSecretValue(ctx, &secretsmanager.GetSecretValueInput{
+		SecretId: aws.String(id),
+	})
+	if err != nil {
+		return "", err
+	}
+	if out.SecretString == nil {
+		return "", fmt.Errorf("secret %s has no string value", id)
+	}
+	if !hasField {
+		return *out.SecretString, nil
+	}
+
+	values := map[string]string{}
+	if err := json.Unmarshal([]byte(*out.SecretString), &values); err != nil {
+		return "", fmt.Errorf("secret %s is not a JSON object of strings", id)
+	}
+	value, ok := values[field]
+	if !ok {
+		return "", fmt.Errorf("secret %s has no field %s", id, field)
+	}
+	return value, nil
+}
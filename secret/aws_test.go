@@ -0,0 +1,93 @@
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/credentials"
+	"github.com/aws/aws-sdk-go-v2/service/secretsmanager"
+	"github.com/aws/aws-sdk-go-v2/service/ssm"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func newAwsStandIn(t *testing.T, handle func(target string, req map[string]interface{}) (int, interface{})) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := map[string]interface{}{}
+		assert.Nil(t, json.NewDecoder(r.Body).Decode(&req))
+
+		status, resp := handle(r.Header.Get("X-Amz-Target"), req)
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		w.WriteHeader(status)
+		assert.Nil(t, json.NewEncoder(w).Encode(resp))
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+var standInCredentials = credentials.NewStaticCredentialsProvider("local", "local", "")
+
+func TestSSMBackend_Get(t *testing.T) {
+	server := newAwsStandIn(t, func(target string, req map[string]interface{}) (int, interface{}) {
+		assert.Equal(t, "AmazonSSM.GetParameter", target)
+		assert.Equal(t, true, req["WithDecryption"])
+		if req["Name"] != "/autop2p/hf" {
+			return 400, map[string]string{"__type": "ParameterNotFound"}
+		}
+		return 200, map[string]interface{}{
+			"Parameter": map[string]string{"Name": "/autop2p/hf", "Value": "hf-password"},
+		}
+	})
+
+	b := NewSSMBackend(ssm.New(ssm.Options{
+		Region:       "ap-northeast-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  standInCredentials,
+	}))
+
+	value, err := b.Get(context.Background(), "/autop2p/hf")
+	assert.Nil(t, err)
+	assert.Equal(t, "hf-password", value)
+
+	_, err = b.Get(context.Background(), "/autop2p/missing")
+	assert.Error(t, err)
+}
+
+func TestSecretsManagerBackend_Get(t *testing.T) {
+	server := newAwsStandIn(t, func(target string, req map[string]interface{}) (int, interface{}) {
+		assert.Equal(t, "secretsmanager.GetSecretValue", target)
+		switch req["SecretId"] {
+		case "autop2p/pf":
+			return 200, map[string]string{"Name": "autop2p/pf", "SecretString": "pf-password"}
+		case "autop2p/accounts":
+			return 200, map[string]string{
+				"Name":         "autop2p/accounts",
+				"SecretString": `{"username":"pf@example.com","password":"pf-password"}`,
+			}
+		default:
+			return 400, map[string]string{"__type": "ResourceNotFoundException"}
+		}
+	})
+
+	b := NewSecretsManagerBackend(secretsmanager.New(secretsmanager.Options{
+		Region:       "ap-northeast-2",
+		BaseEndpoint: aws.String(server.URL),
+		Credentials:  standInCredentials,
+	}))
+
+	value, err := b.Get(context.Background(), "autop2p/pf")
+	assert.Nil(t, err)
+	assert.Equal(t, "pf-password", value)
+
+	value, err = b.Get(context.Background(), "autop2p/accounts#password")
+	assert.Nil(t, err)
+	assert.Equal(t, "pf-password", value)
+
+	_, err = b.Get(context.Background(), "autop2p/accounts#otp")
+	assert.EqualError(t, err, "secret autop2p/accounts has no field otp")
+
+	_, err = b.Get(context.Background(), "autop2p/missing")
+	assert.Error(t, err)
+}
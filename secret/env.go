@@ -0,0 +1,17 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"os"
+)
+
+type EnvBackend struct{}
+
+func (b EnvBackend) Get(ctx context.Context, key string) (string, error) {
+	value, ok := os.LookupEnv(key)
+	if !ok {
+		return "", fmt.Errorf("environment variable %s is not set", key)
+	}
+	return value, nil
+}
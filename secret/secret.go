@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+type Backend interface {
+	Get(ctx context.Context, key string) (string, error)
+}
+
+type Resolver struct {
+	backends map[string]Backend
+}
+
+func NewResolver(backends map[string]Backend) *Resolver {
+	return &Resolver{backends: backends}
+}
+
+type ResolveError struct {
+	Ref string
+	Err error
+}
+
+func (e *ResolveError) Error() string {
+	return fmt.Sprintf("failed to resolve %s: %v", e.Ref, e.Err)
+}
+
+func (e *ResolveError) Unwrap() error {
+	return e.Err
+}
+
+func (r *Resolver) Resolve(ctx context.Context, value string) (string, error) {
+	scheme, key, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+	backend, ok := r.backends[scheme]
+	if !ok {
+		return value, nil
+	}
+
+	resolved, err := backend.Get(ctx, key)
+	if err != nil {
+		return "", &ResolveError{Ref: value, Err: err}
+	}
+	return resolved, nil
+}
+
+type lazyBackend struct {
+	once    sync.Once
+	new     func(ctx context.Context) (Backend, error)
+	backend Backend
+	err     error
+}
+
+func Lazy(new func(ctx context.Context) (Backend, error)) Backend {
+	return &lazyBackend{new: new}
+}
+
+func (b *lazyBackend) Get(ctx context.Context, key string) (string, error) {
+	b.once.Do(func() {
+		b.backend, b.err = b.new(ctx)
+	})
+	if b.err != nil {
+		return "", b.err
+	}
+	return b.backend.Get(ctx, key)
+}
@@ -0,0 +1,71 @@
+package secret
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+type backendFunc func(ctx context.Context, key string) (string, error)
+
+func (f backendFunc) Get(ctx context.Context, key string) (string, error) {
+	return f(ctx, key)
+}
+
+func TestResolver_Resolve(t *testing.T) {
+	t.Setenv("AUTOP2P_TEST_PASSWORD", "p@ss:word")
+
+	r := NewResolver(map[string]Backend{
+		"env": EnvBackend{},
+		"ssm": backendFunc(func(ctx context.Context, key string) (string, error) {
+			return "from " + key, nil
+		}),
+	})
+
+	for value, expected := range map[string]string{
+		"plain":                     "plain",
+		"plain:with:colon":          "plain:with:colon",
+		"env:AUTOP2P_TEST_PASSWORD": "p@ss:word",
+		"ssm:/autop2p/hf":           "from /autop2p/hf",
+	} {
+		resolved, err := r.Resolve(context.Background(), value)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, resolved)
+	}
+}
+
+func TestResolver_Resolve_Error(t *testing.T) {
+	r := NewResolver(map[string]Backend{
+		"env": EnvBackend{},
+	})
+
+	resolved, err := r.Resolve(context.Background(), "env:AUTOP2P_TEST_NOT_SET")
+
+	assert.Empty(t, resolved)
+	assert.IsType(t, &ResolveError{}, err)
+	assert.EqualError(t, err, "failed to resolve env:AUTOP2P_TEST_NOT_SET: environment variable AUTOP2P_TEST_NOT_SET is not set")
+}
+
+func TestLazy(t *testing.T) {
+	created := 0
+	b := Lazy(func(ctx context.Context) (Backend, error) {
+		created += 1
+		return backendFunc(func(ctx context.Context, key string) (string, error) {
+			return key, nil
+		}), nil
+	})
+
+	for i := 0; i < 2; i++ {
+		value, err := b.Get(context.Background(), "key")
+		assert.Nil(t, err)
+		assert.Equal(t, "key", value)
+	}
+	assert.Equal(t, 1, created)
+
+	failed := Lazy(func(ctx context.Context) (Backend, error) {
+		return nil, errors.New("no credentials")
+	})
+	_, err := failed.Get(context.Background(), "key")
+	assert.EqualError(t, err, "no credentials")
+}
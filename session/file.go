@@ -0,0 +1,66 @@
+package session
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/Joddev/autop2p"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+type FileCache struct {
+	dir string
+}
+
+func NewFileCache(dir string) *FileCache {
+	return &FileCache{dir: dir}
+}
+
+func (c *FileCache) Load(ctx context.Context, company autop2p.CompanyType, username string) (string, error) {
+	data, err := ioutil.ReadFile(c.path(company, username))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+func (c *FileCache) Save(ctx context.Context, company autop2p.CompanyType, username string, token string) error {
+	if err := os.MkdirAll(c.dir, 0700); err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(c.dir, ".session-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.WriteString(token); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), c.path(company, username))
+}
+
+func (c *FileCache) Delete(ctx context.Context, company autop2p.CompanyType, username string) error {
+	err := os.Remove(c.path(company, username))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// path hashes the account so usernames never appear in file names.
+func (c *FileCache) path(company autop2p.CompanyType, username string) string {
+	sum := sha256.Sum256([]byte(string(company) + "\x00" + username))
+	return filepath.Join(c.dir, hex.EncodeToString(sum[:]))
+}
@@ -0,0 +1,48 @@
+package session
+
+import (
+	"context"
+	"github.com/Joddev/autop2p"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "sessions")
+	cache := NewFileCache(dir)
+	ctx := context.Background()
+
+	token, err := cache.Load(ctx, autop2p.Honestfund, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+
+	assert.Nil(t, cache.Save(ctx, autop2p.Honestfund, "user", "TOKEN"))
+
+	token, err = cache.Load(ctx, autop2p.Honestfund, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, "TOKEN", token)
+
+	token, err = cache.Load(ctx, autop2p.Peoplefund, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+
+	files, err := ioutil.ReadDir(dir)
+	assert.Nil(t, err)
+	assert.Len(t, files, 1)
+	assert.Equal(t, os.FileMode(0600), files[0].Mode().Perm())
+	assert.NotContains(t, files[0].Name(), "user")
+
+	info, err := os.Stat(dir)
+	assert.Nil(t, err)
+	assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+
+	assert.Nil(t, cache.Delete(ctx, autop2p.Honestfund, "user"))
+	assert.Nil(t, cache.Delete(ctx, autop2p.Honestfund, "user"))
+
+	token, err = cache.Load(ctx, autop2p.Honestfund, "user")
+	assert.Nil(t, err)
+	assert.Equal(t, "", token)
+}
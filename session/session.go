@@ -0,0 +1,120 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
+	"io"
+	"os"
+	"sync"
+)
+
+// LogOutput receives cache failures. The cache only saves logins, so a failing
+// one never fails the session.
+var LogOutput io.Writer = os.Stdout
+
+type Cache interface {
+	Load(ctx context.Context, company autop2p.CompanyType, username string) (string, error)
+	Save(ctx context.Context, company autop2p.CompanyType, username string, token string) error
+	Delete(ctx context.Context, company autop2p.CompanyType, username string) error
+}
+
+type NopCache struct{}
+
+func (NopCache) Load(ctx context.Context, company autop2p.CompanyType, username string) (string, error) {
+	return "", nil
+}
+
+func (NopCache) Save(ctx context.Context, company autop2p.CompanyType, username string, token string) error {
+	return nil
+}
+
+func (NopCache) Delete(ctx context.Context, company autop2p.CompanyType, username string) error {
+	return nil
+}
+
+type LoginFunc func(ctx context.Context) (string, error)
+
+type Session struct {
+	company  autop2p.CompanyType
+	username string
+	cache    Cache
+	login    LoginFunc
+	mu       sync.Mutex
+	token    string
+}
+
+func Open(ctx context.Context, cache Cache, company autop2p.CompanyType, username string, login LoginFunc) (*Session, error) {
+	s := &Session{
+		company:  company,
+		username: username,
+		cache:    cache,
+		login:    login,
+	}
+
+	token, err := cache.Load(ctx, company, username)
+	if err != nil {
+		fmt.Fprintf(LogOutput, "%s %s 세션 캐시 읽기 실패: %v\n", company, username, err)
+	}
+	if token != "" {
+		s.token = token
+		return s, nil
+	}
+
+	if _, err := s.refresh(ctx, ""); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Session) Token() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.token
+}
+
+// Do calls fn with the current token. When the platform reports the session
+// as expired, it logs in again and retries fn once with the new token.
+func (s *Session) Do(ctx context.Context, fn func(token string) error) error {
+	token := s.Token()
+	err := fn(token)
+
+	var expired *util.SessionExpiredError
+	if !errors.As(err, &expired) {
+		return err
+	}
+
+	token, err = s.refresh(ctx, token)
+	if err != nil {
+		return err
+	}
+	return fn(token)
+}
+
+func (s *Session) refresh(ctx context.Context, stale string) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != stale {
+		return s.token, nil
+	}
+
+	if stale != "" {
+		if err := s.cache.Delete(ctx, s.company, s.username); err != nil {
+			fmt.Fprintf(LogOutput, "%s %s 세션 캐시 삭제 실패: %v\n", s.company, s.username, err)
+		}
+	}
+
+	token, err := s.login(ctx)
+	if err != nil {
+		return "", err
+	}
+	s.token = token
+
+	if err := s.cache.Save(ctx, s.company, s.username, token); err != nil {
+		fmt.Fprintf(LogOutput, "%s %s 세션 캐시 저장 실패: %v\n", s.company, s.username, err)
+	}
+	return token, nil
+}
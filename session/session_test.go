@@ -0,0 +1,141 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"github.com/Joddev/autop2p"
+	"github.com/Joddev/autop2p/util"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+type memoryCache map[string]string
+
+func (c memoryCache) Load(ctx context.Context, company autop2p.CompanyType, username string) (string, error) {
+	return c[string(company)+username], nil
+}
+
+func (c memoryCache) Save(ctx context.Context, company autop2p.CompanyType, username string, token string) error {
+	c[string(company)+username] = token
+	return nil
+}
+
+func (c memoryCache) Delete(ctx context.Context, company autop2p.CompanyType, username string) error {
+	delete(c, string(company)+username)
+	return nil
+}
+
+func countingLogin(tokens ...string) (LoginFunc, *int) {
+	count := 0
+	return func(ctx context.Context) (string, error) {
+		count++
+		return tokens[count-1], nil
+	}, &count
+}
+
+func TestOpen_UsesCachedToken(t *testing.T) {
+	cache := memoryCache{"Honestfunduser": "CACHED"}
+	login, count := countingLogin("FRESH")
+
+	s, err := Open(context.Background(), cache, autop2p.Honestfund, "user", login)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "CACHED", s.Token())
+	assert.Equal(t, 0, *count)
+}
+
+func TestOpen_LogsInOnMiss(t *testing.T) {
+	cache := memoryCache{}
+	login, count := countingLogin("FRESH")
+
+	s, err := Open(context.Background(), cache, autop2p.Honestfund, "user", login)
+
+	assert.Nil(t, err)
+	assert.Equal(t, "FRESH", s.Token())
+	assert.Equal(t, 1, *count)
+	assert.Equal(t, "FRESH", cache["Honestfunduser"])
+}
+
+func TestDo_RetriesAfterExpiry(t *testing.T) {
+	cache := memoryCache{"Honestfunduser": "STALE"}
+	login, count := countingLogin("FRESH")
+	s, _ := Open(context.Background(), cache, autop2p.Honestfund, "user", login)
+
+	var used []string
+	err := s.Do(context.Background(), func(token string) error {
+		used = append(used, token)
+		if token == "STALE" {
+			return &util.SessionExpiredError{Url: "https://example.com"}
+		}
+		return nil
+	})
+
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"STALE", "FRESH"}, used)
+	assert.Equal(t, 1, *count)
+	assert.Equal(t, "FRESH", cache["Honestfunduser"])
+}
+
+func TestDo_RetriesOnlyOnce(t *testing.T) {
+	login, count := countingLogin("FIRST", "SECOND")
+	s, _ := Open(context.Background(), NopCache{}, autop2p.Peoplefund, "user", login)
+
+	calls := 0
+	err := s.Do(context.Background(), func(token string) error {
+		calls++
+		return &util.SessionExpiredError{Url: "https://example.com"}
+	})
+
+	assert.IsType(t, &util.SessionExpiredError{}, err)
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, 2, *count)
+}
+
+func TestDo_OtherErrorsAreReturned(t *testing.T) {
+	login, count := countingLogin("TOKEN")
+	s, _ := Open(context.Background(), NopCache{}, autop2p.Peoplefund, "user", login)
+	expected := errors.New("boom")
+
+	err := s.Do(context.Background(), func(token string) error {
+		return expected
+	})
+
+	assert.Equal(t, expected, err)
+	assert.Equal(t, 1, *count)
+}
+
+// brokenCache fails every operation, like a cache directory that can't be written.
+type brokenCache struct{}
+
+func (brokenCache) Load(ctx context.Context, company autop2p.CompanyType, username string) (string, error) {
+	return "", errors.New("read-only file system")
+}
+
+func (brokenCache) Save(ctx context.Context, company autop2p.CompanyType, username string, token string) error {
+	return errors.New("read-only file system")
+}
+
+func (brokenCache) Delete(ctx context.Context, company autop2p.CompanyType, username string) error {
+	return errors.New("read-only file system")
+}
+
+func TestOpen_IgnoresCacheErrors(t *testing.T) {
+	LogOutput = io.Discard
+	login, count := countingLogin("FIRST", "SECOND")
+
+	s, err := Open(context.Background(), brokenCache{}, autop2p.Honestfund, "user", login)
+	assert.Nil(t, err)
+
+	var tokens []string
+	err = s.Do(context.Background(), func(token string) error {
+		tokens = append(tokens, token)
+		if token == "FIRST" {
+			return &util.SessionExpiredError{}
+		}
+		return nil
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"FIRST", "SECOND"}, tokens)
+	assert.Equal(t, 2, *count)
+}
@@ -1,33 +1,203 @@
 package autop2p
 
+import (
+	"fmt"
+	"strings"
+)
+
 type Conf struct {
-	Settings []Setting
+	Settings  []Setting
+	Ledger    LedgerConf
+	Notifiers []NotifierConf
+	Schedule  string
+	Timezone  string
+	Secrets   SecretsConf
+	Session   SessionConf
+	Paging    PagingConf
+	// Concurrency is the number of settings run at once, 4 when zero.
+	Concurrency int
+	RateLimit   RateLimitConf `yaml:"rateLimit"`
+
+	PeoplefundCategories map[string]Category `yaml:"peoplefundCategories"`
+}
+
+type PagingConf struct {
+	PageSize int `yaml:"pageSize"`
+	MaxPages int `yaml:"maxPages"`
+}
+
+// RateLimitConf limits requests per platform host, 5 per second with bursts
+// of 5 when zero.
+type RateLimitConf struct {
+	PerSecond float64 `yaml:"perSecond"`
+	Burst     int
+}
+
+type SessionConf struct {
+	Cache string
+	Dir   string
+}
+
+type SecretsConf struct {
+	Region                 string
+	SSMEndpoint            string `yaml:"ssmEndpoint"`
+	SecretsManagerEndpoint string `yaml:"secretsManagerEndpoint"`
+	AgeIdentityFile        string `yaml:"ageIdentityFile"`
+}
+
+type LedgerConf struct {
+	Type     string
+	Path     string
+	Table    string
+	Region   string
+	Endpoint string
+}
+
+type NotifierConf struct {
+	Type       string
+	WebhookUrl string `yaml:"webhookUrl"`
+	BotToken   string `yaml:"botToken"`
+	ChatId     string `yaml:"chatId"`
+	Url        string
 }
 
 type Setting struct {
-	Username   string
-	Password   string
-	Company    CompanyType
-	Amount     int
-	PeriodMin  int     `yaml:"periodMin"`
-	PeriodMax  int     `yaml:"periodMax"`
-	RateMin    float64 `yaml:"rateMin"`
-	RateMax    float64 `yaml:"rateMax"`
-	Categories []Category
+	Name             string
+	Username         string
+	Password         string
+	Company          CompanyType
+	Amount           int
+	Amounts          []AmountRule
+	PartialFill      bool    `yaml:"partialFill"`
+	PartialFillMin   int     `yaml:"partialFillMin"`
+	PeriodMin        int     `yaml:"periodMin"`
+	PeriodMax        int     `yaml:"periodMax"`
+	RateMin          float64 `yaml:"rateMin"`
+	RateMax          float64 `yaml:"rateMax"`
+	Categories       []Category
+	AllowLaterRounds bool         `yaml:"allowLaterRounds"`
+	InvestorType     InvestorType `yaml:"investorType"`
+	Schedule         string
+	LtvMax           float64  `yaml:"ltvMax"`
+	Grades           []string `yaml:"grades"`
+	CollateralTypes  []string `yaml:"collateralTypes"`
+	MinProgress      float64  `yaml:"minProgress"`
+	Rule             string
+	Sort             SortStrategy
+	Weights          ScoreWeights
+	Policy           Policy
+
+	rule *rule
+}
+
+// CompileRule must be called before Match when Rule is set; LoadConf does so.
+func (s *Setting) CompileRule() error {
+	s.rule = nil
+	if s.Rule == "" {
+		return nil
+	}
+
+	r, err := compileRule(s.Rule)
+	if err != nil {
+		return err
+	}
+	s.rule = r
+	return nil
 }
 
 func (s *Setting) Match(product *Product) bool {
-	if s.Amount > product.RemainAmount {
-		return false
+	return len(s.Explain(product)) == 0
+}
+
+// Explain lists every reason the setting rejects product, not just the first.
+func (s *Setting) Explain(product *Product) []Reason {
+	reasons := append(s.ExplainListing(product), s.explainDetail(product)...)
+	if s.Rule != "" && (s.rule == nil || !s.rule.match(product)) {
+		reasons = append(reasons, Reason{RejectedByRule, s.Rule})
 	}
-	if s.PeriodMax < product.Period || s.PeriodMin > product.Period {
-		return false
+	return reasons
+}
+
+// NeedsDetail reports whether Match depends on fields only Runner.LoadDetail fills.
+func (s *Setting) NeedsDetail() bool {
+	if s.rule != nil && s.rule.needsDetail {
+		return true
+	}
+	// Peoplefund listings have no progress either, only details do.
+	if s.Sort == SortClosing || s.Sort == SortScore && (s.Weights.LTV != 0 || s.Weights.Progress != 0) {
+		return true
+	}
+	return s.LtvMax > 0 || len(s.Grades) > 0 || len(s.CollateralTypes) > 0 || s.MinProgress > 0
+}
+
+// MatchListing checks only the fields every product listing has.
+func (s *Setting) MatchListing(product *Product) bool {
+	return len(s.ExplainListing(product)) == 0
+}
+
+// ExplainListing is Explain limited to the checks MatchListing makes.
+func (s *Setting) ExplainListing(product *Product) []Reason {
+	var reasons []Reason
+	if amount := s.AmountFor(product); amount == 0 {
+		reasons = append(reasons, Reason{NoAmount, "no amount applies to the product"})
+	} else if s.FillAmount(product) == 0 {
+		reasons = append(reasons, Reason{AmountExceedsRemaining,
+			fmt.Sprintf("amount %d is greater than remaining %d", amount, product.RemainAmount)})
+	}
+	// With a rule, omitted upper bounds and categories leave the decision to it.
+	hasRule := s.Rule != ""
+	if s.PeriodMin > product.Period || (s.PeriodMax != 0 || !hasRule) && s.PeriodMax < product.Period {
+		reasons = append(reasons, Reason{PeriodOutOfRange,
+			fmt.Sprintf("period %d is not in [%d, %d]", product.Period, s.PeriodMin, s.PeriodMax)})
+	}
+	if s.RateMin > product.Rate || (s.RateMax != 0 || !hasRule) && s.RateMax < product.Rate {
+		reasons = append(reasons, Reason{RateOutOfRange,
+			fmt.Sprintf("rate %v is not in [%v, %v]", product.Rate, s.RateMin, s.RateMax)})
+	}
+	if (!hasRule || len(s.Categories) > 0) && !containsCategory(s.Categories, product.Category) {
+		reasons = append(reasons, Reason{CategoryNotAllowed,
+			fmt.Sprintf("category %s is not in %v", product.Category, s.Categories)})
+	}
+	return reasons
+}
+
+// explainDetail rejects products whose detail is unknown when a detail filter is set.
+func (s *Setting) explainDetail(product *Product) []Reason {
+	var reasons []Reason
+	if s.LtvMax > 0 && (product.LTV <= 0 || product.LTV > s.LtvMax) {
+		reasons = append(reasons, Reason{LtvTooHigh,
+			fmt.Sprintf("ltv %v is unknown or greater than %v", product.LTV, s.LtvMax)})
+	}
+	if len(s.Grades) > 0 && !contains(s.Grades, product.Grade) {
+		reasons = append(reasons, Reason{GradeNotAllowed,
+			fmt.Sprintf("grade %q is not in %v", product.Grade, s.Grades)})
+	}
+	if len(s.CollateralTypes) > 0 && !contains(s.CollateralTypes, product.CollateralType) {
+		reasons = append(reasons, Reason{CollateralNotAllowed,
+			fmt.Sprintf("collateral %q is not in %v", product.CollateralType, s.CollateralTypes)})
+	}
+	if s.MinProgress > 0 && product.Progress < s.MinProgress {
+		reasons = append(reasons, Reason{ProgressTooLow,
+			fmt.Sprintf("progress %v is less than %v", product.Progress, s.MinProgress)})
+	}
+	return reasons
+}
+
+func containsCategory(categories []Category, c Category) bool {
+	for _, category := range categories {
+		if category == c {
+			return true
+		}
 	}
-	if s.RateMax < product.Rate || s.RateMin > product.Rate {
+	return false
+}
+
+func contains(values []string, v string) bool {
+	if v == "" {
 		return false
 	}
-	for _, c := range s.Categories {
-		if c == product.Category {
+	for _, value := range values {
+		if strings.EqualFold(value, v) {
 			return true
 		}
 	}
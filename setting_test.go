@@ -144,3 +144,72 @@ func TestSetting_Match(t *testing.T) {
 		Category:     PF,
 	}))
 }
+
+func TestSetting_Match_Detail(t *testing.T) {
+	s := &Setting{
+		Amount:          10000,
+		PeriodMax:       12,
+		RateMax:         15,
+		Categories:      []Category{MortgageRealEstate},
+		LtvMax:          70,
+		Grades:          []string{"A1", "A2"},
+		CollateralTypes: []string{"아파트"},
+		MinProgress:     30,
+	}
+	product := Product{
+		RemainAmount:   100000,
+		Period:         6,
+		Rate:           10,
+		Category:       MortgageRealEstate,
+		LTV:            65,
+		Grade:          "a2",
+		CollateralType: "아파트",
+		Progress:       50,
+	}
+
+	assert.True(t, s.NeedsDetail())
+	assert.True(t, s.Match(&product))
+
+	unknownLtv := product
+	unknownLtv.LTV = 0
+	assert.True(t, s.MatchListing(&unknownLtv))
+	assert.False(t, s.Match(&unknownLtv))
+
+	highLtv := product
+	highLtv.LTV = 75
+	assert.False(t, s.Match(&highLtv))
+
+	lowGrade := product
+	lowGrade.Grade = "B1"
+	assert.False(t, s.Match(&lowGrade))
+
+	villa := product
+	villa.CollateralType = "빌라"
+	assert.False(t, s.Match(&villa))
+
+	early := product
+	early.Progress = 10
+	assert.False(t, s.Match(&early))
+}
+
+func TestSetting_Explain(t *testing.T) {
+	s := &Setting{
+		Amount:     10000,
+		PeriodMin:  3,
+		PeriodMax:  12,
+		RateMin:    8,
+		RateMax:    15,
+		Categories: []Category{MortgageRealEstate},
+	}
+
+	reasons := s.Explain(&Product{RemainAmount: 5000, Period: 24, Rate: 10, Category: PF})
+
+	var codes []string
+	for _, r := range reasons {
+		codes = append(codes, r.Code)
+	}
+	assert.Equal(t, []string{AmountExceedsRemaining, PeriodOutOfRange, CategoryNotAllowed}, codes)
+	assert.Equal(t, "PeriodOutOfRange: period 24 is not in [3, 12]", reasons[1].String())
+
+	assert.Empty(t, s.Explain(&Product{RemainAmount: 50000, Period: 6, Rate: 10, Category: MortgageRealEstate}))
+}
@@ -0,0 +1,106 @@
+package util
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type CircuitOpenError struct {
+	Key string
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s is unavailable, skipping requests for now", e.Key)
+}
+
+// BreakerTransport stops sending requests for a key, a platform, after
+// Threshold consecutive failures. After Cooldown a single request is let
+// through; it closes the circuit on success and opens it again on failure.
+type BreakerTransport struct {
+	Base      http.RoundTripper
+	Key       func(req *http.Request) string
+	Threshold int
+	Cooldown  time.Duration
+
+	mu       sync.Mutex
+	circuits map[string]*circuit
+	now      func() time.Time
+}
+
+type circuit struct {
+	failures  int
+	openUntil time.Time
+	probing   bool
+}
+
+func (t *BreakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	key := t.Key(req)
+	if !t.allow(key) {
+		return nil, &CircuitOpenError{Key: key}
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	resp, err := base.RoundTrip(req)
+	// Our own deadline says nothing about the platform, so it only ends a probe.
+	canceled := req.Context().Err() != nil
+	t.record(key, canceled, err != nil || resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests)
+	return resp, err
+}
+
+func (t *BreakerTransport) allow(key string) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.circuit(key)
+	if c.failures < t.Threshold {
+		return true
+	}
+	if c.probing || t.clock().Before(c.openUntil) {
+		return false
+	}
+	c.probing = true
+	return true
+}
+
+func (t *BreakerTransport) record(key string, canceled bool, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	c := t.circuit(key)
+	c.probing = false
+	if canceled {
+		return
+	}
+	if !failed {
+		c.failures = 0
+		return
+	}
+	c.failures++
+	if c.failures >= t.Threshold {
+		c.openUntil = t.clock().Add(t.Cooldown)
+	}
+}
+
+func (t *BreakerTransport) circuit(key string) *circuit {
+	if t.circuits == nil {
+		t.circuits = make(map[string]*circuit)
+	}
+	c, ok := t.circuits[key]
+	if !ok {
+		c = &circuit{}
+		t.circuits[key] = c
+	}
+	return c
+}
+
+func (t *BreakerTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,58 @@
+package util
+
+import (
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestBreakerTransport(t *testing.T) {
+	now := time.Date(2021, 3, 1, 9, 0, 0, 0, time.UTC)
+	down := map[string]bool{"a.test": true}
+	calls := map[string]int{}
+	transport := &BreakerTransport{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls[req.URL.Host]++
+			if down[req.URL.Host] {
+				return nil, errors.New("connection reset")
+			}
+			return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+		}),
+		Key:       func(req *http.Request) string { return req.URL.Host },
+		Threshold: 2,
+		Cooldown:  time.Minute,
+		now:       func() time.Time { return now },
+	}
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 5; i++ {
+		_, err := client.Get("https://a.test/")
+		assert.NotNil(t, err)
+	}
+	assert.Equal(t, 2, calls["a.test"], "open after the threshold")
+
+	_, err := client.Get("https://a.test/")
+	var openErr *CircuitOpenError
+	assert.True(t, errors.As(err, &openErr))
+	assert.Equal(t, "a.test", openErr.Key)
+
+	_, err = client.Get("https://b.test/")
+	assert.Nil(t, err, "other keys are unaffected")
+
+	now = now.Add(time.Minute)
+	_, err = client.Get("https://a.test/")
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, calls["a.test"], "one probe after the cooldown")
+	_, _ = client.Get("https://a.test/")
+	assert.Equal(t, 3, calls["a.test"], "failed probe opens again")
+
+	now = now.Add(time.Minute)
+	down["a.test"] = false
+	_, err = client.Get("https://a.test/")
+	assert.Nil(t, err)
+	_, err = client.Get("https://a.test/")
+	assert.Nil(t, err)
+	assert.Equal(t, 5, calls["a.test"], "successful probe closes")
+}
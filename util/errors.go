@@ -0,0 +1,34 @@
+package util
+
+import "fmt"
+
+type StatusError struct {
+	StatusCode int
+	Url        string
+	Body       string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d from %s: %s", e.StatusCode, e.Url, e.Body)
+}
+
+type DecodeError struct {
+	Url string
+	Err error
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("failed to decode response from %s: %v", e.Url, e.Err)
+}
+
+func (e *DecodeError) Unwrap() error {
+	return e.Err
+}
+
+type SessionExpiredError struct {
+	Url string
+}
+
+func (e *SessionExpiredError) Error() string {
+	return fmt.Sprintf("session expired while requesting %s", e.Url)
+}
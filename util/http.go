@@ -2,38 +2,73 @@ package util
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"net/http"
+	"net/url"
+	"strings"
 )
 
-func HandleResponse(resp *http.Response, err error) *http.Response {
+func NewFormRequest(ctx context.Context, url string, data url.Values) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, "POST", url, strings.NewReader(data.Encode()))
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req, nil
+}
+
+func HandleResponse(resp *http.Response, err error) (*http.Response, error) {
+	if err != nil {
+		return nil, err
 	}
 
 	if resp.StatusCode >= 400 {
 		defer resp.Body.Close()
 		data, _ := ioutil.ReadAll(resp.Body)
-		panic(errors.New(string(data)))
+		return nil, &StatusError{
+			StatusCode: resp.StatusCode,
+			Url:        resp.Request.URL.String(),
+			Body:       string(data),
+		}
 	}
 
-	return resp
+	return resp, nil
 }
 
-func EncodeJsonRequest(req interface{}) *bytes.Buffer {
+func EncodeJsonRequest(req interface{}) (*bytes.Buffer, error) {
 	data, err := json.Marshal(req)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
-	return bytes.NewBuffer(data)
+	return bytes.NewBuffer(data), nil
 }
 
-func DecodeJsonResponse(resp *http.Response, data interface{}) {
+func DecodeJsonResponse(resp *http.Response, data interface{}) error {
 	defer resp.Body.Close()
 	err := json.NewDecoder(resp.Body).Decode(data)
 	if err != nil {
-		panic(err)
+		return &DecodeError{Url: resp.Request.URL.String(), Err: err}
+	}
+	return nil
+}
+
+func HandleSessionResponse(resp *http.Response, err error, loginPath string) (*http.Response, error) {
+	resp, err = HandleResponse(resp, err)
+
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusUnauthorized {
+		return nil, &SessionExpiredError{Url: statusErr.Url}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(resp.Request.URL.Path, loginPath) {
+		resp.Body.Close()
+		return nil, &SessionExpiredError{Url: resp.Request.URL.String()}
 	}
+	return resp, nil
 }
@@ -0,0 +1,91 @@
+package util
+
+import (
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+)
+
+func TestHandleResponse_StatusError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("maintenance"))
+	}))
+	defer server.Close()
+
+	resp, err := HandleResponse(http.Get(server.URL))
+
+	var statusErr *StatusError
+	assert.Nil(t, resp)
+	assert.True(t, errors.As(err, &statusErr))
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+	assert.Equal(t, "maintenance", statusErr.Body)
+}
+
+func TestDecodeJsonResponse_DecodeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html></html>"))
+	}))
+	defer server.Close()
+
+	resp, err := HandleResponse(http.Get(server.URL))
+	assert.Nil(t, err)
+
+	err = DecodeJsonResponse(resp, &struct{}{})
+
+	assert.IsType(t, &DecodeError{}, err)
+}
+
+func TestNewFormRequest_Canceled(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req, err := NewFormRequest(ctx, server.URL, url.Values{"key": {"value"}})
+	assert.Nil(t, err)
+	assert.Equal(t, "application/x-www-form-urlencoded", req.Header.Get("Content-Type"))
+
+	resp, err := HandleResponse(http.DefaultClient.Do(req))
+
+	assert.Nil(t, resp)
+	assert.True(t, errors.Is(err, context.Canceled))
+}
+
+func TestHandleSessionResponse(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/login", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("<html>login</html>"))
+	})
+	mux.HandleFunc("/expired", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/login", http.StatusFound)
+	})
+	mux.HandleFunc("/unauthorized", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+	mux.HandleFunc("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("{}"))
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	for _, path := range []string{"/expired", "/unauthorized"} {
+		resp, err := http.Get(server.URL + path)
+		resp, err = HandleSessionResponse(resp, err, "/login")
+
+		assert.Nil(t, resp, path)
+		assert.IsType(t, &SessionExpiredError{}, err, path)
+	}
+
+	resp, err := http.Get(server.URL + "/ok")
+	resp, err = HandleSessionResponse(resp, err, "/login")
+	assert.Nil(t, err)
+	assert.Nil(t, resp.Body.Close())
+}
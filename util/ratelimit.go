@@ -0,0 +1,66 @@
+package util
+
+import (
+	"golang.org/x/time/rate"
+	"net/http"
+	"sync"
+)
+
+// RateLimitTransport limits requests per host, so concurrent sessions on one
+// platform share its budget without slowing down the other platforms.
+type RateLimitTransport struct {
+	Base http.RoundTripper
+
+	mu       sync.Mutex
+	limit    rate.Limit
+	burst    int
+	limiters map[string]*rate.Limiter
+}
+
+// NewRateLimitTransport allows perSecond requests per host with bursts of
+// burst. A zero perSecond disables limiting.
+func NewRateLimitTransport(base http.RoundTripper, perSecond float64, burst int) *RateLimitTransport {
+	t := &RateLimitTransport{Base: base, limiters: make(map[string]*rate.Limiter)}
+	t.SetLimit(perSecond, burst)
+	return t
+}
+
+// SetLimit changes the limit of every host, including those already seen.
+func (t *RateLimitTransport) SetLimit(perSecond float64, burst int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.limit = rate.Limit(perSecond)
+	if perSecond == 0 {
+		t.limit = rate.Inf
+	}
+	t.burst = max(burst, 1)
+	for _, l := range t.limiters {
+		l.SetLimit(t.limit)
+		l.SetBurst(t.burst)
+	}
+}
+
+func (t *RateLimitTransport) limiter(host string) *rate.Limiter {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	l, ok := t.limiters[host]
+	if !ok {
+		l = rate.NewLimiter(t.limit, t.burst)
+		t.limiters[host] = l
+	}
+	return l
+}
+
+func (t *RateLimitTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.limiter(req.URL.Host).Wait(req.Context()); err != nil {
+		return nil, err
+	}
+
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return base.RoundTrip(req)
+}
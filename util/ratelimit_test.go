@@ -0,0 +1,54 @@
+package util
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"testing"
+	"time"
+)
+
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestRateLimitTransport(t *testing.T) {
+	var hosts []string
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		hosts = append(hosts, req.URL.Host)
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	})
+	client := &http.Client{Transport: NewRateLimitTransport(base, 0.001, 1)}
+
+	get := func(url string) error {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		req, _ := http.NewRequestWithContext(ctx, "GET", url, nil)
+		_, err := client.Do(req)
+		return err
+	}
+
+	assert.Nil(t, get("https://a.test/1"))
+	assert.NotNil(t, get("https://a.test/2"), "second request to the host waits past the deadline")
+	assert.Nil(t, get("https://b.test/1"), "other hosts have their own budget")
+	assert.Equal(t, []string{"a.test", "b.test"}, hosts)
+}
+
+func TestRateLimitTransport_Unlimited(t *testing.T) {
+	count := 0
+	base := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		count++
+		return &http.Response{StatusCode: http.StatusOK, Request: req}, nil
+	})
+	transport := NewRateLimitTransport(base, 0.001, 1)
+	transport.SetLimit(0, 0)
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < 10; i++ {
+		_, err := client.Get("https://a.test/")
+		assert.Nil(t, err)
+	}
+	assert.Equal(t, 10, count)
+}
@@ -0,0 +1,104 @@
+package util
+
+import (
+	"context"
+	"github.com/stretchr/testify/assert"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newRetryClient() *http.Client {
+	return &http.Client{Transport: &RetryTransport{MaxRetries: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Millisecond}}
+}
+
+func TestRetryTransport_Get(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls < 3 {
+			w.WriteHeader(http.StatusBadGateway)
+			return
+		}
+		_, _ = w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	resp, err := HandleResponse(newRetryClient().Get(server.URL))
+
+	assert.Nil(t, err)
+	data, _ := ioutil.ReadAll(resp.Body)
+	assert.Equal(t, "ok", string(data))
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryTransport_GivesUp(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	_, err := HandleResponse(newRetryClient().Get(server.URL))
+
+	assert.IsType(t, &StatusError{}, err)
+	assert.Equal(t, 3, calls)
+}
+
+func TestRetryTransport_Post(t *testing.T) {
+	var bodies []string
+	status := http.StatusInternalServerError
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(data))
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	post := func(retryable bool) error {
+		req, _ := http.NewRequestWithContext(context.Background(), "POST", server.URL, strings.NewReader("amount=10000"))
+		if retryable {
+			req = Retryable(req)
+		}
+		_, err := HandleResponse(newRetryClient().Do(req))
+		return err
+	}
+
+	assert.NotNil(t, post(false))
+	assert.Equal(t, []string{"amount=10000"}, bodies, "a plain POST may have been processed")
+
+	bodies = nil
+	assert.NotNil(t, post(true))
+	assert.Equal(t, []string{"amount=10000", "amount=10000", "amount=10000"}, bodies)
+
+	bodies = nil
+	status = http.StatusTooManyRequests
+	assert.NotNil(t, post(false))
+	assert.Len(t, bodies, 3, "429 means the request was not processed")
+}
+
+func TestRetryTransport_ConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	url := server.URL
+	server.Close()
+
+	calls := 0
+	transport := &RetryTransport{
+		Base: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+			calls++
+			return http.DefaultTransport.RoundTrip(req)
+		}),
+		MaxRetries: 2,
+		BaseDelay:  time.Millisecond,
+		MaxDelay:   time.Millisecond,
+	}
+	req, _ := http.NewRequest("POST", url, strings.NewReader("amount=10000"))
+	_, err := (&http.Client{Transport: transport}).Do(req)
+
+	assert.NotNil(t, err)
+	assert.Equal(t, 3, calls, "a refused connection never reached the platform")
+}
@@ -0,0 +1,6 @@
+package util
+
+import "time"
+
+// KST is the zone both platforms report times in.
+var KST = time.FixedZone("KST", 9*60*60)
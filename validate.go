@@ -0,0 +1,396 @@
+package autop2p
+
+import (
+	"errors"
+	"fmt"
+	"github.com/expr-lang/expr/file"
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+	"sort"
+	"strings"
+	"time"
+)
+
+type ValidationError struct {
+	Line    int
+	Column  int
+	Path    string
+	Message string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d:%d: %s: %s", e.Line, e.Column, e.Path, e.Message)
+}
+
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+func LoadConf(data []byte) (*Conf, error) {
+	root := &yaml.Node{}
+	if err := yaml.Unmarshal(data, root); err != nil {
+		return nil, err
+	}
+
+	conf := &Conf{}
+	if len(root.Content) == 0 {
+		return conf, nil
+	}
+	if err := root.Decode(conf); err != nil {
+		return nil, err
+	}
+
+	if errs := ValidateConf(conf, root.Content[0]); len(errs) > 0 {
+		return nil, errs
+	}
+	return conf, nil
+}
+
+var companies = map[CompanyType]struct{}{
+	Honestfund: {}, Peoplefund: {},
+}
+
+var categories = map[Category]struct{}{
+	MortgageRealEstate: {}, CorporateCredit: {}, PersonalCredit: {}, PF: {}, UNKNOWN: {},
+}
+
+var sortStrategies = map[SortStrategy]struct{}{
+	"": {}, SortRate: {}, SortPeriod: {}, SortClosing: {}, SortRemaining: {}, SortScore: {},
+}
+
+var investorTypes = map[InvestorType]struct{}{
+	"": {}, GeneralInvestor: {}, IncomeQualifiedInvestor: {}, ProfessionalInvestor: {},
+}
+
+func ValidateConf(conf *Conf, node *yaml.Node) ValidationErrors {
+	v := &validator{}
+
+	settingsNode := mappingValue(node, "settings")
+	if len(conf.Settings) == 0 {
+		v.add(node, "settings", "at least one setting is required")
+	}
+	for i := range conf.Settings {
+		v.validateSetting(&conf.Settings[i], sequenceItem(settingsNode, i), fmt.Sprintf("settings[%d]", i))
+	}
+
+	// Settings of one account share its investor limits.
+	type account struct {
+		company  CompanyType
+		username string
+	}
+	firsts := make(map[account]int)
+	for i, s := range conf.Settings {
+		key := account{s.Company, s.Username}
+		first, ok := firsts[key]
+		if !ok {
+			firsts[key] = i
+			continue
+		}
+		if s.InvestorType != conf.Settings[first].InvestorType {
+			path := fmt.Sprintf("settings[%d].investorType", i)
+			v.add(mappingValueOr(sequenceItem(settingsNode, i), "investorType"), path,
+				"investorType %q differs from settings[%d] of the same account", s.InvestorType, first)
+		}
+	}
+
+	v.validateSchedule(conf.Schedule, mappingValueOr(node, "schedule"), "schedule")
+	if _, err := time.LoadLocation(conf.Timezone); err != nil {
+		v.add(mappingValueOr(node, "timezone"), "timezone", "unknown timezone %q", conf.Timezone)
+	}
+
+	v.validateLedger(&conf.Ledger, mappingValue(node, "ledger"), "ledger")
+
+	notifiersNode := mappingValue(node, "notifiers")
+	for i := range conf.Notifiers {
+		v.validateNotifier(&conf.Notifiers[i], sequenceItem(notifiersNode, i), fmt.Sprintf("notifiers[%d]", i))
+	}
+
+	v.validateSession(&conf.Session, mappingValue(node, "session"), "session")
+	v.validatePaging(&conf.Paging, mappingValue(node, "paging"), "paging")
+	if conf.Concurrency < 0 {
+		v.add(mappingValueOr(node, "concurrency"), "concurrency", "concurrency must not be negative, got %d", conf.Concurrency)
+	}
+	v.validateRateLimit(&conf.RateLimit, mappingValue(node, "rateLimit"), "rateLimit")
+
+	categoriesNode := mappingValue(node, "peoplefundCategories")
+	loanTypes := make([]string, 0, len(conf.PeoplefundCategories))
+	for loanType := range conf.PeoplefundCategories {
+		loanTypes = append(loanTypes, loanType)
+	}
+	sort.Strings(loanTypes)
+	for _, loanType := range loanTypes {
+		c := conf.PeoplefundCategories[loanType]
+		if _, ok := categories[c]; !ok {
+			path := fmt.Sprintf("peoplefundCategories[%q]", loanType)
+			v.add(mappingValue(categoriesNode, loanType), path, "unknown category %q", c)
+		}
+	}
+
+	return v.errs
+}
+
+type validator struct {
+	errs ValidationErrors
+}
+
+func (v *validator) add(node *yaml.Node, path string, format string, args ...interface{}) {
+	err := &ValidationError{Path: path, Message: fmt.Sprintf(format, args...)}
+	if node != nil {
+		err.Line, err.Column = node.Line, node.Column
+	}
+	v.errs = append(v.errs, err)
+}
+
+func (v *validator) validateSetting(s *Setting, node *yaml.Node, path string) {
+	field := func(key string) (*yaml.Node, string) {
+		return mappingValueOr(node, key), path + "." + key
+	}
+
+	if s.Username == "" {
+		n, p := field("username")
+		v.add(n, p, "username is required")
+	}
+	if s.Password == "" {
+		n, p := field("password")
+		v.add(n, p, "password is required")
+	}
+	if _, ok := companies[s.Company]; !ok {
+		n, p := field("company")
+		v.add(n, p, "unknown company %q", s.Company)
+	}
+	if s.Amount < 0 || s.Amount == 0 && len(s.Amounts) == 0 {
+		n, p := field("amount")
+		v.add(n, p, "amount must be positive, got %d", s.Amount)
+	} else if s.Amount > 0 {
+		n, p := field("amount")
+		v.validateAmountUnit(s.Company, s.Amount, n, p)
+	}
+	amountsNode, amountsPath := field("amounts")
+	for i := range s.Amounts {
+		v.validateAmountRule(s.Company, &s.Amounts[i], sequenceItem(amountsNode, i), fmt.Sprintf("%s[%d]", amountsPath, i))
+	}
+	// With a rule, a zero max leaves the range open above.
+	hasRule := s.Rule != ""
+	if s.PeriodMin > s.PeriodMax && (s.PeriodMax != 0 || !hasRule) {
+		n, p := field("periodMin")
+		v.add(n, p, "periodMin %d is greater than periodMax %d", s.PeriodMin, s.PeriodMax)
+	}
+	if s.RateMin > s.RateMax && (s.RateMax != 0 || !hasRule) {
+		n, p := field("rateMin")
+		v.add(n, p, "rateMin %v is greater than rateMax %v", s.RateMin, s.RateMax)
+	}
+	categoriesNode, categoriesPath := field("categories")
+	if len(s.Categories) == 0 && !hasRule {
+		v.add(categoriesNode, categoriesPath, "at least one category is required")
+	}
+	for i, c := range s.Categories {
+		if _, ok := categories[c]; !ok {
+			v.add(sequenceItem(categoriesNode, i), fmt.Sprintf("%s[%d]", categoriesPath, i), "unknown category %q", c)
+		}
+	}
+	scheduleNode, schedulePath := field("schedule")
+	v.validateSchedule(s.Schedule, scheduleNode, schedulePath)
+	if _, ok := investorTypes[s.InvestorType]; !ok {
+		n, p := field("investorType")
+		v.add(n, p, "unknown investorType %q", s.InvestorType)
+	}
+	if s.LtvMax < 0 {
+		n, p := field("ltvMax")
+		v.add(n, p, "ltvMax must not be negative, got %v", s.LtvMax)
+	}
+	if s.MinProgress < 0 || s.MinProgress > 100 {
+		n, p := field("minProgress")
+		v.add(n, p, "minProgress must be between 0 and 100, got %v", s.MinProgress)
+	}
+	if s.PartialFillMin < 0 {
+		n, p := field("partialFillMin")
+		v.add(n, p, "partialFillMin must not be negative, got %d", s.PartialFillMin)
+	} else if s.PartialFillMin > 0 {
+		n, p := field("partialFillMin")
+		v.validateAmountUnit(s.Company, s.PartialFillMin, n, p)
+	}
+	if _, ok := sortStrategies[s.Sort]; !ok {
+		n, p := field("sort")
+		v.add(n, p, "unknown sort %q", s.Sort)
+	}
+	if s.Sort == SortScore && s.Weights == (ScoreWeights{}) {
+		n, p := field("weights")
+		v.add(n, p, "weights are required for score sort")
+	}
+	v.validatePolicy(s.Policy, mappingValue(node, "policy"), path+".policy")
+	if err := s.CompileRule(); err != nil {
+		n, p := field("rule")
+		v.addRuleError(n, p, err)
+	}
+}
+
+func (v *validator) validatePolicy(policy Policy, node *yaml.Node, path string) {
+	codes := make([]string, 0, len(policy))
+	for code := range policy {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	for _, code := range codes {
+		n, p := mappingValue(node, code), fmt.Sprintf("%s[%q]", path, code)
+		if _, ok := investErrorCodes[code]; !ok {
+			v.add(n, p, "unknown error code %q", code)
+		} else if _, ok := actions[policy[code]]; !ok {
+			v.add(n, p, "unknown action %q", policy[code])
+		}
+	}
+}
+
+// addRuleError points at the offending character when the rule is a single
+// line scalar, and at the rule itself otherwise.
+func (v *validator) addRuleError(node *yaml.Node, path string, err error) {
+	var exprErr *file.Error
+	if !errors.As(err, &exprErr) {
+		v.add(node, path, "invalid rule: %v", err)
+		return
+	}
+
+	if node != nil && exprErr.Line == 1 && !strings.Contains(node.Value, "\n") {
+		at := *node
+		switch node.Style {
+		case 0:
+			at.Column += exprErr.Column
+		case yaml.DoubleQuotedStyle, yaml.SingleQuotedStyle:
+			at.Column += exprErr.Column + 1
+		}
+		node = &at
+	}
+	v.add(node, path, "invalid rule: %s", exprErr.Message)
+}
+
+// validateSchedule parses spec as the daemon does.
+func (v *validator) validateSchedule(spec string, node *yaml.Node, path string) {
+	if spec == "" {
+		return
+	}
+	if _, err := cron.ParseStandard(spec); err != nil {
+		v.add(node, path, "invalid schedule %q: %v", spec, err)
+	}
+}
+
+func (v *validator) validateLedger(l *LedgerConf, node *yaml.Node, path string) {
+	switch l.Type {
+	case "", "file":
+	case "dynamodb":
+		if l.Table == "" {
+			v.add(node, path+".table", "table is required for dynamodb ledger")
+		}
+	default:
+		v.add(mappingValueOr(node, "type"), path+".type", "unknown ledger type %q", l.Type)
+	}
+}
+
+func (v *validator) validateAmountRule(company CompanyType, r *AmountRule, node *yaml.Node, path string) {
+	field := func(key string) (*yaml.Node, string) {
+		return mappingValueOr(node, key), path + "." + key
+	}
+
+	if r.Amount <= 0 {
+		n, p := field("amount")
+		v.add(n, p, "amount must be positive, got %d", r.Amount)
+	} else {
+		n, p := field("amount")
+		v.validateAmountUnit(company, r.Amount, n, p)
+	}
+	if r.RateMax != 0 && r.RateMin > r.RateMax {
+		n, p := field("rateMin")
+		v.add(n, p, "rateMin %v is greater than rateMax %v", r.RateMin, r.RateMax)
+	}
+	if r.PeriodMax != 0 && r.PeriodMin > r.PeriodMax {
+		n, p := field("periodMin")
+		v.add(n, p, "periodMin %d is greater than periodMax %d", r.PeriodMin, r.PeriodMax)
+	}
+	categoriesNode, categoriesPath := field("categories")
+	for i, c := range r.Categories {
+		if _, ok := categories[c]; !ok {
+			v.add(sequenceItem(categoriesNode, i), fmt.Sprintf("%s[%d]", categoriesPath, i), "unknown category %q", c)
+		}
+	}
+}
+
+func (v *validator) validateAmountUnit(company CompanyType, amount int, node *yaml.Node, path string) {
+	if unit := company.InvestUnit(); company.RoundAmount(amount) != amount {
+		v.add(node, path, "amount %d must be a multiple of %d and at least %d for %s", amount, unit.Step, unit.Min, company)
+	}
+}
+
+func (v *validator) validatePaging(p *PagingConf, node *yaml.Node, path string) {
+	if p.PageSize < 0 {
+		v.add(mappingValueOr(node, "pageSize"), path+".pageSize", "pageSize must not be negative, got %d", p.PageSize)
+	}
+	if p.MaxPages < 0 {
+		v.add(mappingValueOr(node, "maxPages"), path+".maxPages", "maxPages must not be negative, got %d", p.MaxPages)
+	}
+}
+
+func (v *validator) validateRateLimit(r *RateLimitConf, node *yaml.Node, path string) {
+	if r.PerSecond < 0 {
+		v.add(mappingValueOr(node, "perSecond"), path+".perSecond", "perSecond must not be negative, got %g", r.PerSecond)
+	}
+	if r.Burst < 0 {
+		v.add(mappingValueOr(node, "burst"), path+".burst", "burst must not be negative, got %d", r.Burst)
+	}
+}
+
+func (v *validator) validateSession(s *SessionConf, node *yaml.Node, path string) {
+	switch s.Cache {
+	case "", "file", "none":
+	default:
+		v.add(mappingValueOr(node, "cache"), path+".cache", "unknown session cache %q", s.Cache)
+	}
+}
+
+func (v *validator) validateNotifier(n *NotifierConf, node *yaml.Node, path string) {
+	switch n.Type {
+	case "slack":
+		if n.WebhookUrl == "" {
+			v.add(node, path+".webhookUrl", "webhookUrl is required for slack notifier")
+		}
+	case "telegram":
+		if n.BotToken == "" || n.ChatId == "" {
+			v.add(node, path, "botToken and chatId are required for telegram notifier")
+		}
+	case "webhook":
+		if n.Url == "" {
+			v.add(node, path+".url", "url is required for webhook notifier")
+		}
+	default:
+		v.add(mappingValueOr(node, "type"), path+".type", "unknown notifier type %q", n.Type)
+	}
+}
+
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func mappingValueOr(node *yaml.Node, key string) *yaml.Node {
+	if n := mappingValue(node, key); n != nil {
+		return n
+	}
+	return node
+}
+
+func sequenceItem(node *yaml.Node, i int) *yaml.Node {
+	if node == nil || node.Kind != yaml.SequenceNode || i >= len(node.Content) {
+		return node
+	}
+	return node.Content[i]
+}
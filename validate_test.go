@@ -0,0 +1,280 @@
+package autop2p
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestLoadConf(t *testing.T) {
+	conf, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Honestfund
+    amount: 10000
+    periodMin: 0
+    periodMax: 12
+    rateMin: 0
+    rateMax: 24
+    categories:
+      - PF
+      - CorporateCredit
+`))
+
+	assert.Nil(t, err)
+	assert.Len(t, conf.Settings, 1)
+	assert.Equal(t, []Category{PF, CorporateCredit}, conf.Settings[0].Categories)
+}
+
+func TestLoadConf_ValidationErrors(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    company: Honestfund
+    amount: 10000
+    periodMin: 0
+    periodMax: 12
+    rateMin: 0
+    rateMax: 24
+    categories:
+      - PfRealEstate
+      - CorporateCredit
+  - username: username
+    password: password
+    company: Honestfunds
+    amount: 0
+    periodMin: 12
+    periodMax: 6
+    rateMin: 10
+    rateMax: 5
+    categories:
+      - PF
+notifiers:
+  - type: email
+session:
+  cache: redis
+paging:
+  maxPages: -1
+peoplefundCategories:
+  개인신용: Personal
+  법인신용: CorporateCredit
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Line: 3, Column: 5, Path: "settings[0].password", Message: "password is required"},
+		{Line: 11, Column: 9, Path: "settings[0].categories[0]", Message: `unknown category "PfRealEstate"`},
+		{Line: 15, Column: 14, Path: "settings[1].company", Message: `unknown company "Honestfunds"`},
+		{Line: 16, Column: 13, Path: "settings[1].amount", Message: "amount must be positive, got 0"},
+		{Line: 17, Column: 16, Path: "settings[1].periodMin", Message: "periodMin 12 is greater than periodMax 6"},
+		{Line: 19, Column: 14, Path: "settings[1].rateMin", Message: "rateMin 10 is greater than rateMax 5"},
+		{Line: 24, Column: 11, Path: "notifiers[0].type", Message: `unknown notifier type "email"`},
+		{Line: 26, Column: 10, Path: "session.cache", Message: `unknown session cache "redis"`},
+		{Line: 28, Column: 13, Path: "paging.maxPages", Message: "maxPages must not be negative, got -1"},
+		{Line: 30, Column: 9, Path: `peoplefundCategories["개인신용"]`, Message: `unknown category "Personal"`},
+	}, errs)
+	assert.Contains(t, err.Error(), `11:9: settings[0].categories[0]: unknown category "PfRealEstate"`)
+}
+
+func TestLoadConf_TypeError(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - amount: many
+`))
+
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), "line 3")
+}
+
+func TestLoadConf_Rule(t *testing.T) {
+	conf, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Peoplefund
+    amount: 10000
+    rule: Category == "PersonalCredit" && Rate >= 12
+`))
+
+	assert.Nil(t, err)
+	assert.True(t, conf.Settings[0].Match(&Product{Category: PersonalCredit, Rate: 12.5, Period: 12, RemainAmount: 10000}))
+	assert.False(t, conf.Settings[0].Match(&Product{Category: PersonalCredit, Rate: 10, Period: 12, RemainAmount: 10000}))
+}
+
+func TestLoadConf_RuleWithMinOnly(t *testing.T) {
+	conf, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Peoplefund
+    amount: 10000
+    periodMin: 6
+    rateMin: 10
+    rule: Category == "PersonalCredit"
+`))
+
+	assert.Nil(t, err)
+	assert.True(t, conf.Settings[0].Match(&Product{Category: PersonalCredit, Rate: 12.5, Period: 24, RemainAmount: 10000}))
+	assert.False(t, conf.Settings[0].Match(&Product{Category: PersonalCredit, Rate: 8, Period: 24, RemainAmount: 10000}))
+}
+
+func TestLoadConf_RuleError(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Peoplefund
+    amount: 10000
+    rule: Rate >= 12 && Ttle contains "SCF"
+  - username: username
+    password: password
+    company: Peoplefund
+    amount: 10000
+    rule: "Rate * 2"
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 2)
+	assert.Equal(t, 7, errs[0].Line)
+	assert.Equal(t, 25, errs[0].Column)
+	assert.Equal(t, "settings[0].rule", errs[0].Path)
+	assert.Contains(t, errs[0].Message, "unknown name Ttle")
+	assert.Equal(t, 12, errs[1].Line)
+	assert.Equal(t, "settings[1].rule", errs[1].Path)
+	assert.Contains(t, errs[1].Message, "expected bool")
+}
+
+func TestLoadConf_Amounts(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Honestfund
+    periodMax: 12
+    rateMax: 24
+    categories: [MortgageRealEstate]
+    amounts:
+      - categories: [MortgageRealEstate]
+        rateMin: 12
+        rateMax: 10
+        amount: 15000
+      - amount: 50000
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Line: 13, Column: 17, Path: "settings[0].amounts[0].amount", Message: "amount 15000 must be a multiple of 10000 and at least 10000 for Honestfund"},
+		{Line: 11, Column: 18, Path: "settings[0].amounts[0].rateMin", Message: "rateMin 12 is greater than rateMax 10"},
+	}, errs)
+}
+
+func TestLoadConf_PartialFillMin(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Honestfund
+    amount: 50000
+    periodMax: 12
+    rateMax: 24
+    categories: [MortgageRealEstate]
+    partialFill: true
+    partialFillMin: 15000
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Line: 11, Column: 21, Path: "settings[0].partialFillMin", Message: "amount 15000 must be a multiple of 10000 and at least 10000 for Honestfund"},
+	}, errs)
+}
+
+func TestLoadConf_Policy(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Honestfund
+    amount: 10000
+    periodMax: 12
+    rateMax: 24
+    categories: [MortgageRealEstate]
+    policy:
+      InsufficientBalance: stopCompany
+      Duplicated: skip
+      Timeout: abortRun
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Line: 12, Column: 19, Path: `settings[0].policy["Duplicated"]`, Message: `unknown action "skip"`},
+		{Line: 13, Column: 16, Path: `settings[0].policy["Timeout"]`, Message: `unknown error code "Timeout"`},
+	}, errs)
+}
+
+func TestLoadConf_AccountInvestorType(t *testing.T) {
+	_, err := LoadConf([]byte(`
+settings:
+  - username: username
+    password: password
+    company: Honestfund
+    amount: 10000
+    periodMax: 12
+    rateMax: 24
+    categories: [MortgageRealEstate]
+    investorType: general
+  - username: username
+    password: password
+    company: Honestfund
+    amount: 10000
+    periodMax: 12
+    rateMax: 24
+    categories: [PF]
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Equal(t, ValidationErrors{
+		{Line: 11, Column: 5, Path: "settings[1].investorType", Message: `investorType "" differs from settings[0] of the same account`},
+	}, errs)
+}
+
+func TestLoadConf_Schedule(t *testing.T) {
+	_, err := LoadConf([]byte(`
+schedule: "0 13 * *"
+timezone: Asia/Nowhere
+settings:
+  - username: username
+    password: password
+    company: Peoplefund
+    amount: 10000
+    periodMax: 12
+    rateMax: 24
+    categories: [PF]
+    schedule: "CRON_TZ=UTC 0 4 * * *"
+  - username: username2
+    password: password
+    company: Peoplefund
+    amount: 10000
+    periodMax: 12
+    rateMax: 24
+    categories: [PF]
+    schedule: "61 * * * *"
+`))
+
+	errs, ok := err.(ValidationErrors)
+	assert.True(t, ok)
+	assert.Len(t, errs, 3)
+	assert.Equal(t, "settings[1].schedule", errs[0].Path)
+	assert.Equal(t, 20, errs[0].Line)
+	assert.Equal(t, 15, errs[0].Column)
+	assert.Equal(t, "schedule", errs[1].Path)
+	assert.Equal(t, 2, errs[1].Line)
+	assert.Equal(t, 11, errs[1].Column)
+	assert.Equal(t, "timezone", errs[2].Path)
+	assert.Equal(t, 3, errs[2].Line)
+}